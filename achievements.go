@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"io/fs"
+	"log"
+	"time"
+)
+
+const (
+	achievementsFile = "achievements.json"
+	TOAST_TICKS      = 180
+)
+
+// AchievementDef is read from assets/achievements.json, name and description come from the
+// messages ach_<id> and ach_<id>_desc
+type AchievementDef struct {
+	ID   string `json:"id"`
+	Stat string `json:"stat"`
+	Goal int    `json:"goal"`
+	Run  bool   `json:"run"` // the goal has to be reached in a single run instead of over all runs
+}
+
+var AchievementDefs []AchievementDef
+
+func loadAchievementDefs() error {
+	b, err := fs.ReadFile(Assets, "assets/achievements.json")
+	if err != nil {
+		return err
+	}
+	var defs []AchievementDef
+	if err := json.Unmarshal(b, &defs); err != nil {
+		return err
+	}
+	AchievementDefs = defs
+	return nil
+}
+
+// stats counted by the game
+const (
+	StatDifficulty   = "difficulty"
+	StatNoLeft       = "seconds_no_left"
+	StatMoversPassed = "movers_passed"
+	StatDeaths       = "deaths"
+)
+
+// Achievements is the saved progress, totals add up over every run and best keeps the best single run
+type Achievements struct {
+	Totals   map[string]int    `json:"totals"`
+	Best     map[string]int    `json:"best"`
+	Unlocked map[string]string `json:"unlocked"` // id to the date it was unlocked
+
+	run     map[string]int
+	noLeft  int // ticks since the local player last pressed left
+	toasts  []string
+	toastAt int
+	dirty   bool
+	cheated bool // progress stops for the rest of the session
+}
+
+func LoadAchievements() *Achievements {
+	a := &Achievements{}
+	if b, err := loadData(achievementsFile); err == nil && b != nil {
+		if err := json.Unmarshal(b, a); err != nil {
+			log.Println("Achievement progress is corrupted:", err)
+			a = &Achievements{}
+		}
+	}
+	if a.Totals == nil {
+		a.Totals = map[string]int{}
+	}
+	if a.Best == nil {
+		a.Best = map[string]int{}
+	}
+	if a.Unlocked == nil {
+		a.Unlocked = map[string]string{}
+	}
+	a.run = map[string]int{}
+	return a
+}
+
+func (a *Achievements) Save() {
+	if !a.dirty {
+		return
+	}
+	b, err := json.MarshalIndent(a, "", "\t")
+	if err == nil {
+		err = saveData(achievementsFile, b)
+	}
+	if err != nil {
+		log.Println("Cannot save achievements:", err)
+	}
+	a.dirty = false
+}
+
+func (a *Achievements) StartRun() {
+	a.run = map[string]int{}
+	a.noLeft = 0
+	a.Save()
+}
+
+// Add counts up a stat in the run and the totals
+func (a *Achievements) Add(stat string, n int) {
+	a.Totals[stat] += n
+	a.Set(stat, a.run[stat]+n)
+}
+
+// Set raises a stat measured within the run
+func (a *Achievements) Set(stat string, v int) {
+	a.run[stat] = v
+	if v > a.Best[stat] {
+		a.Best[stat] = v
+	}
+	a.dirty = true
+	a.check()
+}
+
+// Progress is how far the achievement is, capped at its goal
+func (a *Achievements) Progress(def AchievementDef) int {
+	if def.Run {
+		return min(a.Best[def.Stat], def.Goal)
+	}
+	return min(a.Totals[def.Stat], def.Goal)
+}
+
+// check saves right away when something unlocks, the game may be closed before the run ends
+func (a *Achievements) check() {
+	unlocked := false
+	for _, def := range AchievementDefs {
+		if _, ok := a.Unlocked[def.ID]; ok || a.Progress(def) < def.Goal {
+			continue
+		}
+		a.Unlocked[def.ID] = time.Now().UTC().Format(time.DateOnly)
+		a.toasts = append(a.toasts, def.ID)
+		log.Println("Achievement unlocked:", def.ID)
+		unlocked = true
+	}
+	if unlocked {
+		a.Save()
+	}
+}
+
+// Toast is the achievement shown right now, empty when there is none
+func (a *Achievements) Toast() string {
+	if len(a.toasts) == 0 {
+		return ""
+	}
+	return a.toasts[0]
+}
+
+// tick runs with every simulation tick, cheated sessions make no progress
+func (a *Achievements) tick(e Ticked) {
+	if len(a.toasts) > 0 {
+		a.toastAt++
+		if a.toastAt >= TOAST_TICKS {
+			a.toasts, a.toastAt = a.toasts[1:], 0
+		}
+	}
+
+	if a.cheated || !e.Playing || e.Local.Dead {
+		return
+	}
+	if e.Local.Left {
+		a.noLeft = 0
+		return
+	}
+	a.noLeft++
+	if a.noLeft%60 == 0 {
+		a.Set(StatNoLeft, max(a.run[StatNoLeft], a.noLeft/60))
+	}
+}
+
+func (a *Achievements) Listen(bus *Bus) {
+	Subscribe(bus, a.tick)
+	Subscribe(bus, func(Cheated) { a.cheated = true })
+	Subscribe(bus, func(RunStarted) { a.StartRun() })
+	Subscribe(bus, func(RunEnded) { a.Save() })
+	Subscribe(bus, func(e PlayerDied) {
+		if !a.cheated && e.Local {
+			a.Add(StatDeaths, 1)
+			a.Save()
+		}
+	})
+	Subscribe(bus, func(e DifficultyRaised) {
+		if !a.cheated {
+			a.Set(StatDifficulty, e.Level)
+		}
+	})
+	Subscribe(bus, func(e PlatformReleased) {
+		if !a.cheated && e.Passed && e.Type == PlatformMoveHorizontal {
+			a.Add(StatMoversPassed, 1)
+		}
+	})
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"io/fs"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"github.com/yohamta/ganim8/v2"
+)
+
+type LoopMode string
+
+const (
+	Loop         LoopMode = "loop"
+	PauseAtEnd   LoopMode = "pause_at_end"
+	PauseAtStart LoopMode = "pause_at_start"
+)
+
+// AnimationMeta describes one animation on the atlas.
+// Frames uses the ganim8 grid notation: alternating columns and rows, each a number or a "from-to" range
+type AnimationMeta struct {
+	Frame     [2]int        `json:"frame"`
+	Origin    [2]int        `json:"origin"`
+	Frames    []interface{} `json:"frames"`
+	Duration  int           `json:"duration"`  // ms for every frame
+	Durations []int         `json:"durations"` // ms per frame, overrides Duration
+	Loop      LoopMode      `json:"loop"`
+}
+
+type AtlasMeta struct {
+	Image      string                   `json:"image"`
+	Animations map[string]AnimationMeta `json:"animations"`
+}
+
+func LoadAtlasMeta(data []byte) (*AtlasMeta, error) {
+	meta := &AtlasMeta{}
+	if err := json.Unmarshal(data, meta); err != nil {
+		return nil, err
+	}
+
+	for name, a := range meta.Animations {
+		if a.Frame[0] <= 0 || a.Frame[1] <= 0 {
+			return nil, fmt.Errorf("animation %s: frame size must be positive", name)
+		}
+		if len(a.Frames) == 0 || len(a.Frames)%2 != 0 {
+			return nil, fmt.Errorf("animation %s: frames must be column and row pairs", name)
+		}
+		for i, f := range a.Frames {
+			if v, ok := f.(float64); ok {
+				a.Frames[i] = int(v)
+			}
+			if _, _, err := frameSpan(a.Frames[i]); err != nil {
+				return nil, fmt.Errorf("animation %s: %w", name, err)
+			}
+		}
+	}
+
+	return meta, nil
+}
+
+// frameSpan reads one column or row of the frames notation
+func frameSpan(f interface{}) (from, to int, err error) {
+	number := func(s string) (int, bool) {
+		if s == "" || strings.Trim(s, "0123456789") != "" {
+			return 0, false
+		}
+		n, err := strconv.Atoi(s)
+		return n, err == nil
+	}
+
+	switch v := f.(type) {
+	case int:
+		return v, v, nil
+	case string:
+		a, b, isRange := strings.Cut(strings.TrimSpace(v), "-")
+		if !isRange {
+			b = a
+		}
+		from, ok := number(a)
+		to, ok2 := number(b)
+		if ok && ok2 {
+			return min(from, to), max(from, to), nil
+		}
+	}
+	return 0, 0, fmt.Errorf("frame %v is neither a number nor a range", f)
+}
+
+// frames returns the frame rectangles of an animation on an atlas of w by h pixels,
+// checked here because ganim8 exits the game on frames outside its grid
+func (m *AtlasMeta) frames(name string, w, h int) ([]*image.Rectangle, error) {
+	a, ok := m.Animations[name]
+	if !ok {
+		return nil, fmt.Errorf("cannot find animation %s", name)
+	}
+	if a.Frame[0] > w || a.Frame[1] > h {
+		return nil, fmt.Errorf("animation %s: frame is larger than the %dx%d atlas", name, w, h)
+	}
+
+	grid := [2]int{w / a.Frame[0], h / a.Frame[1]}
+	for i, f := range a.Frames {
+		from, to, err := frameSpan(f)
+		if err != nil {
+			return nil, fmt.Errorf("animation %s: %w", name, err)
+		}
+		if from < 1 || to > grid[i%2] {
+			return nil, fmt.Errorf("animation %s: frame %v is outside the %dx%d atlas", name, f, w, h)
+		}
+	}
+
+	frames := ganim8.NewGrid(a.Frame[0], a.Frame[1], w, h, a.Origin[0], a.Origin[1]).Frames(a.Frames...)
+	for _, r := range frames {
+		if !r.In(image.Rect(0, 0, w, h)) {
+			return nil, fmt.Errorf("animation %s: frame %v is outside the %dx%d atlas", name, *r, w, h)
+		}
+	}
+	if len(a.Durations) > 0 && len(a.Durations) != len(frames) {
+		return nil, fmt.Errorf("animation %s has %d frames but %d durations", name, len(frames), len(a.Durations))
+	}
+	return frames, nil
+}
+
+// check makes sure every animation the game asks for is there and every animation fits the atlas
+func (m *AtlasMeta) check(w, h int) error {
+	for name := range placeholderAnimations {
+		if _, ok := m.Animations[name]; !ok {
+			return fmt.Errorf("animation %s is missing", name)
+		}
+	}
+	for name := range m.Animations {
+		if _, err := m.frames(name, w, h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AtlasFrames returns the frame rectangles of an animation
+func AtlasFrames(name string) ([]*image.Rectangle, error) {
+	return Animations.frames(name, AtlasW, AtlasH)
+}
+
+// NewAnimation builds a ganim8 animation on the atlas by its name in the metadata
+func NewAnimation(name string) (*ganim8.Animation, error) {
+	a := Animations.Animations[name]
+	frames, err := AtlasFrames(name)
+	if err != nil {
+		return nil, err
+	}
+
+	//ganim8 divides by the total duration
+	if a.Duration <= 0 {
+		a.Duration = 100
+	}
+
+	var durations interface{} = time.Duration(a.Duration) * time.Millisecond
+	if len(a.Durations) > 0 {
+		d := make([]time.Duration, len(a.Durations))
+		for i, ms := range a.Durations {
+			d[i] = time.Duration(ms) * time.Millisecond
+		}
+		durations = d
+	}
+
+	switch a.Loop {
+	case PauseAtEnd:
+		return ganim8.New(Atlas, frames, durations, ganim8.PauseAtEnd), nil
+	case PauseAtStart:
+		return ganim8.New(Atlas, frames, durations, ganim8.PauseAtStart), nil
+	default:
+		return ganim8.New(Atlas, frames, durations), nil
+	}
+}
+
+// animationOrBlank is for the animations the loader already checked, it logs
+// instead of stopping the game should one still be missing
+func animationOrBlank(name string) *ganim8.Animation {
+	anim, err := NewAnimation(name)
+	if err != nil {
+		log.Println("Animation failed:", err)
+		return ganim8.New(Atlas, []*image.Rectangle{{}}, time.Second)
+	}
+	return anim
+}
+
+func readAtlasMeta(file string) (*AtlasMeta, error) {
+	b, err := fs.ReadFile(Assets, file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot find a file %s: %w", file, err)
+	}
+
+	meta, err := LoadAtlasMeta(b)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %w", file, err)
+	}
+	return meta, nil
+}
+
+func loadAtlas(file string) (*ebiten.Image, *AtlasMeta, error) {
+	meta, err := readAtlasMeta(file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	img, err := readImage(meta.Image)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ebiten.NewImageFromImage(img), meta, nil
+}
+
+const atlasFile = "assets/atlas.json"
+
+// loadAtlasAssets only replaces the current atlas when the new one loaded completely
+func loadAtlasAssets() error {
+	atlas, meta, err := loadAtlas(atlasFile)
+	if err != nil {
+		return err
+	}
+	if err := meta.check(atlas.Bounds().Dx(), atlas.Bounds().Dy()); err != nil {
+		return fmt.Errorf("%s: %w", atlasFile, err)
+	}
+
+	Atlas, Animations = atlas, meta
+	AtlasW, AtlasH = Atlas.Bounds().Dx(), Atlas.Bounds().Dy()
+	return nil
+}
+
+// animations the game cannot start without, used when even the metadata is broken
+var placeholderAnimations = map[string]AnimationMeta{
+	"tower":           {Frame: [2]int{192, 32}, Frames: []interface{}{1, 1}},
+	"tower_back":      {Frame: [2]int{192, 32}, Frames: []interface{}{1, 1}},
+	"platform":        {Frame: [2]int{32, 16}, Origin: [2]int{0, 32}, Frames: []interface{}{1, 1}},
+	"mover":           {Frame: [2]int{16, 16}, Origin: [2]int{32, 32}, Frames: []interface{}{1, 1}},
+	"player":          {Frame: [2]int{16, 16}, Origin: [2]int{48, 32}, Frames: []interface{}{1, 1}},
+	"particle_trail":  {Frame: [2]int{4, 4}, Origin: [2]int{64, 32}, Frames: []interface{}{1, 1}},
+	"particle_debris": {Frame: [2]int{4, 4}, Origin: [2]int{64, 32}, Frames: []interface{}{1, 1}},
+	"particle_spark":  {Frame: [2]int{4, 4}, Origin: [2]int{64, 32}, Frames: []interface{}{1, 1}},
+}
+
+// usePlaceholderAtlas draws every frame as an outlined box, keeping the metadata if it can still be read
+// and has every animation
+func usePlaceholderAtlas() {
+	meta, err := readAtlasMeta(atlasFile)
+	if err == nil {
+		size := meta.extent()
+		err = meta.check(max(size.X, 1), max(size.Y, 1))
+	}
+	if err != nil {
+		meta = &AtlasMeta{Animations: placeholderAnimations}
+	}
+
+	size := meta.extent()
+	Atlas = ebiten.NewImage(max(size.X, 1), max(size.Y, 1))
+	AtlasW, AtlasH = Atlas.Bounds().Dx(), Atlas.Bounds().Dy()
+	Animations = meta
+
+	for name := range meta.Animations {
+		frames, _ := AtlasFrames(name)
+		for _, r := range frames {
+			x, y, w, h := float32(r.Min.X), float32(r.Min.Y), float32(r.Dx()), float32(r.Dy())
+			vector.DrawFilledRect(Atlas, x, y, w, h, color.Black, false)
+			vector.StrokeRect(Atlas, x+0.5, y+0.5, w-1, h-1, 1, color.White, false)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"log"
+	"path"
+
+	"github.com/AndriiPets/1Bit/synth"
+	"github.com/hajimehoshi/ebiten/v2/audio"
+	"github.com/hajimehoshi/ebiten/v2/audio/vorbis"
+	"github.com/hajimehoshi/ebiten/v2/audio/wav"
+)
+
+const SAMPLE_RATE = 44100
+
+type Sound int
+
+const (
+	SoundDeath Sound = iota
+	SoundLevelUp
+	SoundPickup
+	SoundMenu
+	SoundJump
+)
+
+// effects are synthesized from the presets on startup instead of shipped as files
+var soundPresets = map[Sound]string{
+	SoundDeath:   "death",
+	SoundLevelUp: "levelup",
+	SoundPickup:  "pickup",
+	SoundMenu:    "menu",
+	SoundJump:    "jump",
+}
+
+const (
+	presetsFile = "assets/sfx/presets.json"
+	musicFile   = "assets/music/theme.wav"
+)
+
+type AudioManager struct {
+	context *audio.Context
+	effects map[Sound][]byte
+	playing []*audio.Player
+	held    []*audio.Player
+	music   *audio.Player
+
+	Master  float64
+	Music   float64
+	Effects float64
+	Muted   bool
+	paused  bool
+}
+
+func NewAudioManager() *AudioManager {
+	a := &AudioManager{
+		context: audio.NewContext(SAMPLE_RATE),
+		effects: make(map[Sound][]byte),
+		Master:  1.0,
+		Music:   0.5,
+		Effects: 0.8,
+	}
+
+	//a missing sound only makes the game quieter, never stops it
+	presets, err := a.loadPresets()
+	if err != nil {
+		log.Println("Cannot load sound presets:", presetsFile, err)
+	}
+	for s, name := range soundPresets {
+		p, ok := presets[name]
+		if !ok {
+			log.Println("Missing sound preset:", name)
+			continue
+		}
+		a.effects[s] = p.Render(SAMPLE_RATE)
+	}
+
+	stream, err := a.decode(musicFile)
+	if err != nil {
+		log.Println("Cannot load music:", musicFile, err)
+		return a
+	}
+	music, err := a.context.NewPlayer(audio.NewInfiniteLoop(stream, stream.Length()))
+	if err != nil {
+		log.Println("Cannot play music:", musicFile, err)
+		return a
+	}
+	a.music = music
+
+	return a
+}
+
+func (a *AudioManager) loadPresets() (map[string]synth.Preset, error) {
+	b, err := fs.ReadFile(Assets, presetsFile)
+	if err != nil {
+		return nil, err
+	}
+	return synth.LoadPresets(b)
+}
+
+type audioStream interface {
+	io.ReadSeeker
+	Length() int64
+}
+
+// decode picks the decoder by file extension
+func (a *AudioManager) decode(file string) (audioStream, error) {
+	b, err := fs.ReadFile(Assets, file)
+	if err != nil {
+		return nil, err
+	}
+
+	if path.Ext(file) == ".ogg" {
+		return vorbis.DecodeWithSampleRate(SAMPLE_RATE, bytes.NewReader(b))
+	}
+	return wav.DecodeWithSampleRate(SAMPLE_RATE, bytes.NewReader(b))
+}
+
+func (a *AudioManager) volume(channel float64) float64 {
+	if a.Muted {
+		return 0
+	}
+	return a.Master * channel
+}
+
+func (a *AudioManager) Play(s Sound) {
+	pcm, ok := a.effects[s]
+	if !ok {
+		return
+	}
+
+	p := a.context.NewPlayerFromBytes(pcm)
+	p.SetVolume(a.volume(a.Effects))
+	p.Play()
+	a.playing = append(a.playing, p)
+}
+
+func (a *AudioManager) PlayMusic() {
+	if a.music == nil {
+		return
+	}
+	a.music.SetVolume(a.volume(a.Music))
+	if !a.paused {
+		a.music.Play()
+	}
+}
+
+// SetPaused holds music and running effects until the game resumes,
+// effects played while paused (menu sounds) are not held
+func (a *AudioManager) SetPaused(paused bool) {
+	if a.paused == paused {
+		return
+	}
+	a.paused = paused
+
+	if paused {
+		for _, p := range a.playing {
+			p.Pause()
+		}
+		a.held, a.playing = a.playing, nil
+	} else {
+		for _, p := range a.held {
+			p.Play()
+		}
+		a.playing, a.held = append(a.playing, a.held...), nil
+	}
+
+	if a.music != nil {
+		if paused {
+			a.music.Pause()
+		} else {
+			a.music.Play()
+		}
+	}
+}
+
+func (a *AudioManager) ToggleMute() {
+	a.Muted = !a.Muted
+	a.applyVolume()
+}
+
+func (a *AudioManager) applyVolume() {
+	if a.music != nil {
+		a.music.SetVolume(a.volume(a.Music))
+	}
+	for _, p := range a.playing {
+		p.SetVolume(a.volume(a.Effects))
+	}
+	for _, p := range a.held {
+		p.SetVolume(a.volume(a.Effects))
+	}
+}
+
+// Update drops effect players that finished
+func (a *AudioManager) Update() {
+	alive := a.playing[:0]
+	for _, p := range a.playing {
+		if p.IsPlaying() {
+			alive = append(alive, p)
+		} else {
+			p.Close()
+		}
+	}
+	a.playing = alive
+}
+
+func (a *AudioManager) Listen(bus *Bus) {
+	Subscribe(bus, func(PlayerDied) { a.Play(SoundDeath) })
+	Subscribe(bus, func(DifficultyRaised) { a.Play(SoundLevelUp) })
+	Subscribe(bus, func(NearMiss) { a.Play(SoundPickup) })
+}
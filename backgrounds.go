@@ -1,8 +1,6 @@
 package main
 
 import (
-	"time"
-
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/yohamta/ganim8/v2"
 )
@@ -85,14 +83,8 @@ var (
 	offset1, offset2 = float64(WORLD_HEIGTH - HALF_HEIGHT), float64(WORLD_HEIGTH - (HALF_HEIGHT + SCREEN_HEIGHT))
 )
 
-func (t *TowerBackground) Draw(world *ebiten.Image, playerPosX, playerPosY float64) {
-	//t.viewport.move(playerPosX, playerPosY, t.tower)
-
-	for i := range 15 {
-		offset := 32 * i
-		t.drawSegment(t.tower, ganim8.DrawOpts(0, float64(SCREEN_HEIGHT-offset)))
-	}
-
+// scrollTower moves the tower of every view down once per tick
+func scrollTower() {
 	offset1 += GameSpeed
 	offset2 += GameSpeed
 	if offset1 >= float64(WORLD_HEIGTH+HALF_HEIGHT) {
@@ -101,6 +93,15 @@ func (t *TowerBackground) Draw(world *ebiten.Image, playerPosX, playerPosY float
 	if offset2 >= float64(WORLD_HEIGTH+HALF_HEIGHT) {
 		offset2 = float64(WORLD_HEIGTH - (HALF_HEIGHT + SCREEN_HEIGHT))
 	}
+}
+
+func (t *TowerBackground) Draw(world *ebiten.Image, playerPosX, playerPosY float64) {
+	//t.viewport.move(playerPosX, playerPosY, t.tower)
+
+	for i := range 15 {
+		offset := 32 * i
+		t.drawSegment(t.tower, ganim8.DrawOpts(0, float64(SCREEN_HEIGHT-offset)))
+	}
 
 	op1 := &ebiten.DrawImageOptions{}
 	op1.GeoM.Translate(playerPosX-96, offset1)
@@ -127,8 +128,6 @@ func (t *TowerBackground) Draw(world *ebiten.Image, playerPosX, playerPosY float
 }
 
 func (t *TowerBackground) SetupAnimation() {
-	grid := ganim8.NewGrid(192, 32, AtlasW, AtlasH)
-
-	t.anim = ganim8.New(Atlas, grid.Frames(1, "16-1"), time.Millisecond*30)
-	t.animBack = ganim8.New(Atlas, grid.Frames(1, "1-16"), time.Millisecond*30)
+	t.anim = animationOrBlank("tower")
+	t.animBack = animationOrBlank("tower_back")
 }
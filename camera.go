@@ -3,21 +3,63 @@ package main
 import (
 	"fmt"
 	"math"
+	"math/rand"
 
 	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
 )
 
+// zoom levels the camera can be switched between with Q/E
+var ZoomPresets = []int{0, 24, 48, 72, 96}
+
+// PixelZoomPresets take over in pixel-perfect mode, whole scales keep every world pixel square
+var PixelZoomPresets = []float64{1, 2, 3}
+
 type Camera struct {
 	ViewPort   Vec2
 	Position   Vec2
+	Bounds     Vec2
 	ZoomFactor int
 	Rotation   int
+
+	Damping    float64 // 0 snaps to the target, closer to 1 follows slower
+	DeadZone   Vec2    // distance the target can move before the camera follows
+	LookAhead  float64 // vertical offset per unit of scroll speed
+	ShakeMax   float64 // offset in pixels at full trauma
+	ShakeDecay float64 // trauma lost per tick
+	ShakeScale float64 // accessibility setting, 0 turns shaking off
+
+	PixelPerfect bool // set with SetPixelPerfect, whole scales and positions so world pixels stay square
+
+	zoom       float64 // eased scale
+	zoomPreset int
+	trauma     float64
+	shake      Vec2
+}
+
+// NewCamera starts at a preset of ZoomPresets, a pixel-perfect camera at the closest whole scale to it
+func NewCamera(viewPort, bounds, pos Vec2, zoomPreset int, pixelPerfect bool) *Camera {
+	c := &Camera{
+		ViewPort:   viewPort,
+		Bounds:     bounds,
+		Position:   pos,
+		Damping:    0.85,
+		DeadZone:   Vec2{8, 24},
+		LookAhead:  12,
+		ShakeMax:   10,
+		ShakeDecay: 0.02,
+		ShakeScale: 1,
+	}
+	c.SetZoomPreset(zoomPreset)
+	c.SetPixelPerfect(pixelPerfect)
+	c.zoom = c.targetScale()
+	return c
 }
 
 func (c *Camera) String() string {
 	return fmt.Sprintf(
-		"T: %.1f, R: %d, Zoom: %d",
-		c.Position, c.Rotation, c.ZoomFactor,
+		"T: %.1f, R: %d, Zoom: %.2f",
+		c.Position, c.Rotation, c.scale(),
 	)
 }
 
@@ -28,40 +70,125 @@ func (c *Camera) viewportCenter() Vec2 {
 	}
 }
 
+func (c *Camera) scale() float64 {
+	return c.zoom
+}
+
+// targetScale is where the zoom eases to, a whole scale from PixelZoomPresets in pixel-perfect mode
+func (c *Camera) targetScale() float64 {
+	if c.PixelPerfect {
+		s := PixelZoomPresets[min(c.zoomPreset, len(PixelZoomPresets)-1)]
+		return math.Max(s, math.Ceil(c.minScale()))
+	}
+	return math.Pow(1.01, float64(c.ZoomFactor))
+}
+
+// smallest scale at which the viewport still fits inside the world image
+func (c *Camera) minScale() float64 {
+	return math.Max(c.ViewPort[0]/c.Bounds[0], c.ViewPort[1]/c.Bounds[1])
+}
+
+// smallest zoom factor at which the viewport still fits inside the world image
+func (c *Camera) minZoom() int {
+	return int(math.Ceil(math.Log(c.minScale()) / math.Log(1.01)))
+}
+
+func (c *Camera) presets() int {
+	if c.PixelPerfect {
+		return len(PixelZoomPresets)
+	}
+	return len(ZoomPresets)
+}
+
+func (c *Camera) SetZoomPreset(inx int) {
+	inx = max(0, min(inx, c.presets()-1))
+	c.zoomPreset = inx
+	c.ZoomFactor = max(ZoomPresets[inx], c.minZoom())
+}
+
+// SetPixelPerfect swaps the preset list, keeping the preset closest to the current zoom
+func (c *Camera) SetPixelPerfect(on bool) {
+	if c.PixelPerfect == on {
+		return
+	}
+	was := c.targetScale()
+	c.PixelPerfect = on
+
+	best, bestDiff := 0, math.Inf(1)
+	for i := range c.presets() {
+		c.SetZoomPreset(i)
+		if d := math.Abs(c.targetScale() - was); d < bestDiff {
+			best, bestDiff = i, d
+		}
+	}
+	c.SetZoomPreset(best)
+}
+
+// Shake adds trauma, the offset grows with the square of it so small hits stay subtle
+func (c *Camera) Shake(trauma float64) {
+	c.trauma = math.Min(c.trauma+trauma, 1)
+}
+
 func (c *Camera) worldMatrix() ebiten.GeoM {
 	m := ebiten.GeoM{}
-	m.Translate(-c.Position[0], -c.Position[1])
+	x, y := c.Position[0]+c.shake[0], c.Position[1]+c.shake[1]
+	if c.PixelPerfect {
+		x, y = math.Round(x), math.Round(y)
+	}
+	m.Translate(-x, -y)
 
 	//Scale and rotate around the center of the screen
 	//m.Translate(-c.viewportCenter()[0], -c.viewportCenter()[1])
-	m.Scale(
-		math.Pow(1.01, float64(c.ZoomFactor)),
-		math.Pow(1.01, float64(c.ZoomFactor)),
-	)
+	m.Scale(c.scale(), c.scale())
 	m.Translate(c.viewportCenter()[0], c.viewportCenter()[1])
 	return m
 }
 
+// Render also works on a sub image of the canvas, the view is moved to where it starts
 func (c *Camera) Render(world, screen *ebiten.Image) {
+	m := c.worldMatrix()
+	origin := screen.Bounds().Min
+	m.Translate(float64(origin.X), float64(origin.Y))
 	screen.DrawImage(world, &ebiten.DrawImageOptions{
-		GeoM: c.worldMatrix(),
+		GeoM: m,
 	})
 }
 
-func (c *Camera) Update(pos Vec2) {
-	c.Position[0] = pos[0]
+func (c *Camera) Update(pos Vec2, scrollSpeed float64) {
+	if inpututil.IsKeyJustPressed(ebiten.KeyQ) {
+		c.SetZoomPreset(c.zoomPreset - 1)
+	}
 
-	if ebiten.IsKeyPressed(ebiten.KeyQ) {
-		if c.ZoomFactor > -2400 {
-			c.ZoomFactor -= 1
-		}
+	if inpututil.IsKeyJustPressed(ebiten.KeyE) {
+		c.SetZoomPreset(c.zoomPreset + 1)
 	}
 
-	if ebiten.IsKeyPressed(ebiten.KeyE) {
-		if c.ZoomFactor < 2400 {
-			c.ZoomFactor += 1
+	//snap once close so pixel-perfect zoom comes to rest on the whole scale
+	target := c.targetScale()
+	c.zoom += (target - c.zoom) * (1 - c.Damping)
+	if math.Abs(target-c.zoom) < 0.001 {
+		c.zoom = target
+	}
+
+	//look towards the incoming platforms, further the faster they come
+	follow := Vec2{pos[0], pos[1] - c.LookAhead*scrollSpeed}
+
+	for i := range 2 {
+		d := follow[i] - c.Position[i]
+		if math.Abs(d) > c.DeadZone[i] {
+			d -= math.Copysign(c.DeadZone[i], d)
+			c.Position[i] += d * (1 - c.Damping)
 		}
 	}
+
+	//keep the view inside the world image
+	halfW, halfH := c.ViewPort[0]/c.scale()/2, c.ViewPort[1]/c.scale()/2
+	c.Position[0] = math.Max(halfW, math.Min(c.Position[0], c.Bounds[0]-halfW))
+	c.Position[1] = math.Max(halfH, math.Min(c.Position[1], c.Bounds[1]-halfH))
+
+	c.trauma = math.Max(c.trauma-c.ShakeDecay, 0)
+	amount := c.ShakeMax * c.ShakeScale * c.trauma * c.trauma
+	c.shake = Vec2{amount * (rand.Float64()*2 - 1), amount * (rand.Float64()*2 - 1)}
 }
 
 func (c *Camera) ScreenToWorld(posX, posY int) (float64, float64) {
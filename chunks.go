@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+const chunksDir = "assets/chunks"
+
+type ChunkPlatform struct {
+	Type string    `json:"type"`
+	Pos  Vec2      `json:"pos"`
+	Path []float64 `json:"path,omitempty"` // replaces the path of the platform type when set
+}
+
+// Chunk is a hand made wave, platform positions are in the same spawn band Generate fills
+type Chunk struct {
+	Name      string          `json:"-"`
+	Weight    int             `json:"weight"`
+	Platforms []ChunkPlatform `json:"platforms"`
+}
+
+// Chunks is every file in assets/chunks, sorted by name
+var Chunks []*Chunk
+
+func platformTypeByName(name string) (PlatformType, bool) {
+	for _, t := range PlatformTypes {
+		if t.String() == name {
+			return t, true
+		}
+	}
+	return PlatformNormal, false
+}
+
+func parseChunk(name string, b []byte) (*Chunk, error) {
+	c := &Chunk{Name: name, Weight: 1}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("chunk %s: %w", name, err)
+	}
+	for _, p := range c.Platforms {
+		if _, ok := platformTypeByName(p.Type); !ok {
+			return nil, fmt.Errorf("chunk %s: unknown platform %s", name, p.Type)
+		}
+	}
+	return c, nil
+}
+
+// loadChunks treats a missing folder as no chunks, the spawner then only generates
+func loadChunks() error {
+	entries, err := fs.ReadDir(Assets, chunksDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		Chunks = nil
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var chunks []*Chunk
+	for _, e := range entries {
+		if path.Ext(e.Name()) != ".json" {
+			continue
+		}
+		b, err := fs.ReadFile(Assets, path.Join(chunksDir, e.Name()))
+		if err != nil {
+			return err
+		}
+		c, err := parseChunk(strings.TrimSuffix(e.Name(), ".json"), b)
+		if err != nil {
+			return err
+		}
+		chunks = append(chunks, c)
+	}
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].Name < chunks[j].Name })
+
+	Chunks = chunks
+	return nil
+}
+
+func (c *Chunk) Encode() ([]byte, error) {
+	return json.MarshalIndent(c, "", "\t")
+}
+
+// pickChunk returns nil when this wave should be generated instead
+func pickChunk() *Chunk {
+	if len(Chunks) == 0 || worldRand.Float64() >= Tuning.ChunkChance {
+		return nil
+	}
+
+	total := 0
+	for _, c := range Chunks {
+		total += max(c.Weight, 0)
+	}
+	if total == 0 {
+		return nil
+	}
+
+	dice := worldRand.Intn(total)
+	for _, c := range Chunks {
+		dice -= max(c.Weight, 0)
+		if dice < 0 {
+			return c
+		}
+	}
+	return nil
+}
+
+func (ps *PlatformSpawner) SpawnChunk(c *Chunk) {
+	for _, p := range c.Platforms {
+		pType, _ := platformTypeByName(p.Type)
+		ps.SpawnPath(p.Pos, pType, "platform", p.Path)
+	}
+}
@@ -0,0 +1,617 @@
+// Command atlaspack packs individual frames and strips into the game atlas
+// and writes the animation metadata the game loads from assets/atlas.json.
+//
+// Every PNG in the input folder becomes one animation named after the file.
+// A file named like "player_16x16.png" is a strip cut into 16x16 frames,
+// other files are a single frame. A subfolder is one animation made of the
+// PNG frames inside it in name order.
+//
+// An optional pack.json in the input folder sets durations and loop modes,
+// and can derive animations from the frames of another one:
+//
+//	{
+//		"tower": {"duration": 30},
+//		"tower_back": {"source": "tower", "reverse": true, "duration": 30},
+//		"particle_spark": {"source": "particles", "frames": [2, 3]}
+//	}
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// same layout as AtlasMeta in the game
+type animationMeta struct {
+	Frame     [2]int        `json:"frame"`
+	Origin    [2]int        `json:"origin"`
+	Frames    []interface{} `json:"frames"`
+	Duration  int           `json:"duration,omitempty"`
+	Durations []int         `json:"durations,omitempty"`
+	Loop      string        `json:"loop,omitempty"`
+}
+
+type atlasMeta struct {
+	Image      string                   `json:"image"`
+	Animations map[string]animationMeta `json:"animations"`
+}
+
+type packEntry struct {
+	Source    string `json:"source"`
+	Frames    []int  `json:"frames"`
+	Reverse   bool   `json:"reverse"`
+	Duration  int    `json:"duration"`
+	Durations []int  `json:"durations"`
+	Loop      string `json:"loop"`
+}
+
+// block is a sheet of equally sized frames placed on the atlas as a whole
+type block struct {
+	name       string
+	img        image.Image
+	frameW     int
+	frameH     int
+	cols, rows int
+	x, y       int
+}
+
+var stripName = regexp.MustCompile(`^(.+)_(\d+)x(\d+)$`)
+
+func main() {
+	in := flag.String("in", "", "folder with the frames and strips to pack")
+	out := flag.String("out", "assets/tile_atlas.png", "atlas image to write")
+	metaPath := flag.String("meta", "assets/atlas.json", "animation metadata to write")
+	width := flag.Int("width", 384, "atlas width in pixels")
+	dryRun := flag.Bool("dry-run", false, "only check the input and report changes")
+	flag.Parse()
+
+	if *in == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	blocks, err := readBlocks(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if errs := checkPalette(blocks); len(errs) > 0 {
+		for _, err := range errs {
+			log.Println(err)
+		}
+		log.Fatal("input is not 1-bit, use only black, white and fully transparent pixels")
+	}
+
+	height, err := pack(blocks, *width)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	entries, err := readPackFile(filepath.Join(*in, "pack.json"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	old, err := readMeta(*metaPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	meta, err := buildMeta(blocks, entries, old)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if meta.Image == "" {
+		meta.Image = filepath.ToSlash(*out)
+	}
+
+	atlas := composeAtlas(blocks, *width, height)
+	oldAtlas, err := readPNG(*out)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Fatal(err)
+	}
+	report(old, meta, oldAtlas, atlas)
+
+	if *dryRun {
+		return
+	}
+
+	if err := writeAtlas(*out, atlas); err != nil {
+		log.Fatal(err)
+	}
+	if err := writeMeta(*metaPath, meta); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func readBlocks(dir string) ([]*block, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var blocks []*block
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+
+		if e.IsDir() {
+			b, err := readFrameFolder(e.Name(), path)
+			if err != nil {
+				return nil, err
+			}
+			blocks = append(blocks, b)
+			continue
+		}
+
+		if filepath.Ext(e.Name()) != ".png" {
+			continue
+		}
+
+		img, err := readPNG(path)
+		if err != nil {
+			return nil, err
+		}
+
+		name := strings.TrimSuffix(e.Name(), ".png")
+		b := &block{name: name, img: img, frameW: img.Bounds().Dx(), frameH: img.Bounds().Dy()}
+
+		if m := stripName.FindStringSubmatch(name); m != nil {
+			b.name = m[1]
+			b.frameW, _ = strconv.Atoi(m[2])
+			b.frameH, _ = strconv.Atoi(m[3])
+			if b.frameW == 0 || b.frameH == 0 || img.Bounds().Dx()%b.frameW != 0 || img.Bounds().Dy()%b.frameH != 0 {
+				return nil, fmt.Errorf("%s: size %v is not a multiple of the %dx%d frame", path, img.Bounds().Size(), b.frameW, b.frameH)
+			}
+		}
+
+		b.cols, b.rows = img.Bounds().Dx()/b.frameW, img.Bounds().Dy()/b.frameH
+		blocks = append(blocks, b)
+	}
+
+	seen := make(map[string]bool)
+	for _, b := range blocks {
+		if seen[b.name] {
+			return nil, fmt.Errorf("animation %s comes from more than one file", b.name)
+		}
+		seen[b.name] = true
+	}
+
+	return blocks, nil
+}
+
+// readFrameFolder lays the frames of a folder out in a single row
+func readFrameFolder(name, dir string) (*block, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.png"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s: no frames", dir)
+	}
+	sort.Strings(files)
+
+	var frames []image.Image
+	for _, f := range files {
+		img, err := readPNG(f)
+		if err != nil {
+			return nil, err
+		}
+		if len(frames) > 0 && img.Bounds().Size() != frames[0].Bounds().Size() {
+			return nil, fmt.Errorf("%s: frame size %v differs from %v", f, img.Bounds().Size(), frames[0].Bounds().Size())
+		}
+		frames = append(frames, img)
+	}
+
+	w, h := frames[0].Bounds().Dx(), frames[0].Bounds().Dy()
+	sheet := image.NewNRGBA(image.Rect(0, 0, w*len(frames), h))
+	for i, f := range frames {
+		draw.Draw(sheet, image.Rect(i*w, 0, (i+1)*w, h), f, f.Bounds().Min, draw.Src)
+	}
+
+	return &block{name: name, img: sheet, frameW: w, frameH: h, cols: len(frames), rows: 1}, nil
+}
+
+func readPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return img, nil
+}
+
+func checkPalette(blocks []*block) []error {
+	var errs []error
+	for _, b := range blocks {
+		bad := 0
+		var first image.Point
+		bounds := b.img.Bounds()
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				r, g, bl, a := b.img.At(x, y).RGBA()
+				if a == 0 || (a == 0xffff && r == g && g == bl && (r == 0 || r == 0xffff)) {
+					continue
+				}
+				if bad == 0 {
+					first = image.Pt(x, y)
+				}
+				bad++
+			}
+		}
+		if bad > 0 {
+			errs = append(errs, fmt.Errorf("%s: %d pixels outside the palette, first at %v", b.name, bad, first))
+		}
+	}
+	return errs
+}
+
+// pack places the blocks on shelves, tallest first, and returns the atlas height
+func pack(blocks []*block, width int) (int, error) {
+	order := make([]*block, len(blocks))
+	copy(order, blocks)
+	sort.SliceStable(order, func(i, j int) bool {
+		hi, hj := order[i].img.Bounds().Dy(), order[j].img.Bounds().Dy()
+		if hi != hj {
+			return hi > hj
+		}
+		return order[i].name < order[j].name
+	})
+
+	x, y, shelf := 0, 0, 0
+	for _, b := range order {
+		w, h := b.img.Bounds().Dx(), b.img.Bounds().Dy()
+		if w > width {
+			return 0, fmt.Errorf("%s is %d pixels wide, more than the atlas width %d", b.name, w, width)
+		}
+		if x+w > width {
+			x, y, shelf = 0, y+shelf, 0
+		}
+		b.x, b.y = x, y
+		x += w
+		shelf = max(shelf, h)
+	}
+
+	height := y + shelf
+	return (height + 15) / 16 * 16, nil
+}
+
+func readPackFile(path string) (map[string]packEntry, error) {
+	entries := make(map[string]packEntry)
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return entries, nil
+}
+
+func readMeta(path string) (*atlasMeta, error) {
+	meta := &atlasMeta{Animations: make(map[string]animationMeta)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return meta, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, meta); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return meta, nil
+}
+
+func buildMeta(blocks []*block, entries map[string]packEntry, old *atlasMeta) (*atlasMeta, error) {
+	meta := &atlasMeta{Image: old.Image, Animations: make(map[string]animationMeta)}
+
+	byName := make(map[string]*block)
+	for _, b := range blocks {
+		byName[b.name] = b
+		meta.Animations[b.name] = animationMeta{
+			Frame:  [2]int{b.frameW, b.frameH},
+			Origin: [2]int{b.x, b.y},
+			Frames: b.frames(nil),
+		}
+	}
+
+	for name, e := range entries {
+		if e.Source == "" {
+			continue
+		}
+		b, ok := byName[e.Source]
+		if !ok {
+			return nil, fmt.Errorf("pack.json: %s uses unknown source %s", name, e.Source)
+		}
+		if _, ok := byName[name]; ok {
+			return nil, fmt.Errorf("pack.json: %s is both a file and derived from %s", name, e.Source)
+		}
+
+		order := e.Frames
+		if len(order) == 0 {
+			for i := range b.cols * b.rows {
+				order = append(order, i+1)
+			}
+		}
+		if e.Reverse {
+			reversed := make([]int, len(order))
+			for i, f := range order {
+				reversed[len(order)-1-i] = f
+			}
+			order = reversed
+		}
+		for _, f := range order {
+			if f < 1 || f > b.cols*b.rows {
+				return nil, fmt.Errorf("pack.json: %s uses frame %d but %s has %d", name, f, b.name, b.cols*b.rows)
+			}
+		}
+
+		meta.Animations[name] = animationMeta{
+			Frame:  [2]int{b.frameW, b.frameH},
+			Origin: [2]int{b.x, b.y},
+			Frames: b.frames(order),
+		}
+	}
+
+	//timing comes from pack.json, or is kept from the last pack so it can be tuned by hand
+	for name, a := range meta.Animations {
+		if e, ok := entries[name]; ok {
+			a.Duration, a.Durations, a.Loop = e.Duration, e.Durations, e.Loop
+		} else if prev, ok := old.Animations[name]; ok {
+			a.Duration, a.Durations, a.Loop = prev.Duration, prev.Durations, prev.Loop
+		}
+		if a.Duration == 0 && len(a.Durations) == 0 {
+			a.Duration = 100
+		}
+		meta.Animations[name] = a
+	}
+
+	return meta, nil
+}
+
+// frames converts frame numbers, counted row by row, to the ganim8 column and row notation,
+// runs along a row or a column are written as ranges
+func (b *block) frames(order []int) []interface{} {
+	if order == nil {
+		for i := range b.cols * b.rows {
+			order = append(order, i+1)
+		}
+	}
+
+	cell := func(f int) (int, int) {
+		return (f-1)%b.cols + 1, (f-1)/b.cols + 1
+	}
+	span := func(from, to int) interface{} {
+		if from == to {
+			return from
+		}
+		return fmt.Sprintf("%d-%d", from, to)
+	}
+
+	var out []interface{}
+	for i := 0; i < len(order); {
+		c, r := cell(order[i])
+
+		//prefer a run along the row, fall back to one along the column
+		if j := run(order, i, func(f int) (int, int) { c, r := cell(f); return r, c }); j > i+1 {
+			end, _ := cell(order[j-1])
+			out = append(out, span(c, end), r)
+			i = j
+			continue
+		}
+
+		j := run(order, i, cell)
+		_, end := cell(order[j-1])
+		out = append(out, c, span(r, end))
+		i = j
+	}
+
+	return out
+}
+
+// run returns where the frames starting at i stop keeping the first coordinate
+// and stepping the second one by one in the same direction
+func run(order []int, i int, key func(int) (int, int)) int {
+	fixed, _ := key(order[i])
+	step := 0
+
+	j := i + 1
+	for ; j < len(order); j++ {
+		f, m := key(order[j])
+		_, prev := key(order[j-1])
+		d := m - prev
+		if f != fixed || (d != 1 && d != -1) || (step != 0 && d != step) {
+			break
+		}
+		step = d
+	}
+	return j
+}
+
+// report lists what changed in the metadata, and the frames whose pixels changed when the old atlas is there
+func report(old, meta *atlasMeta, oldAtlas, atlas image.Image) {
+	fmt.Printf("atlas %dx%d, %d animations\n", atlas.Bounds().Dx(), atlas.Bounds().Dy(), len(meta.Animations))
+
+	names := make([]string, 0, len(meta.Animations))
+	for name := range meta.Animations {
+		names = append(names, name)
+	}
+	for name := range old.Animations {
+		if _, ok := meta.Animations[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changes := 0
+	for _, name := range names {
+		a, inNew := meta.Animations[name]
+		o, inOld := old.Animations[name]
+
+		switch {
+		case !inOld:
+			fmt.Printf("  + %s %dx%d at %v\n", name, a.Frame[0], a.Frame[1], a.Origin)
+		case !inNew:
+			fmt.Printf("  - %s\n", name)
+		default:
+			var diff []string
+			if a.Frame != o.Frame {
+				diff = append(diff, fmt.Sprintf("frame %v -> %v", o.Frame, a.Frame))
+			}
+			if a.Origin != o.Origin {
+				diff = append(diff, fmt.Sprintf("moved %v -> %v", o.Origin, a.Origin))
+			}
+			if fmt.Sprint(a.Frames) != fmt.Sprint(o.Frames) {
+				diff = append(diff, fmt.Sprintf("frames %v -> %v", o.Frames, a.Frames))
+			}
+			if oldAtlas != nil {
+				if changed := changedFrames(o, a, oldAtlas, atlas); len(changed) > 0 {
+					diff = append(diff, fmt.Sprintf("pixels of frames %v", changed))
+				}
+			}
+			if len(diff) == 0 {
+				continue
+			}
+			fmt.Printf("  ~ %s: %s\n", name, strings.Join(diff, ", "))
+		}
+		changes++
+	}
+
+	if changes == 0 {
+		fmt.Println("  no changes")
+	}
+}
+
+// cells expands a ganim8 frame list of column and row pairs, either of them can be a range like "1-4"
+func cells(frames []interface{}) [][2]int {
+	span := func(v interface{}) []int {
+		switch v := v.(type) {
+		case int:
+			return []int{v}
+		case float64:
+			return []int{int(v)}
+		case string:
+			var from, to int
+			if _, err := fmt.Sscanf(v, "%d-%d", &from, &to); err != nil {
+				return nil
+			}
+			step := 1
+			if to < from {
+				step = -1
+			}
+			var out []int
+			for i := from; i != to+step; i += step {
+				out = append(out, i)
+			}
+			return out
+		}
+		return nil
+	}
+
+	var out [][2]int
+	for i := 0; i+1 < len(frames); i += 2 {
+		for _, r := range span(frames[i+1]) {
+			for _, c := range span(frames[i]) {
+				out = append(out, [2]int{c, r})
+			}
+		}
+	}
+	return out
+}
+
+// changedFrames compares each frame of an animation in the old and new atlas pixel by pixel,
+// frames are numbered from 1 in animation order
+func changedFrames(o, a animationMeta, oldAtlas, atlas image.Image) []int {
+	rect := func(m animationMeta, cell [2]int) image.Rectangle {
+		x := m.Origin[0] + (cell[0]-1)*m.Frame[0]
+		y := m.Origin[1] + (cell[1]-1)*m.Frame[1]
+		return image.Rect(x, y, x+m.Frame[0], y+m.Frame[1])
+	}
+
+	oldCells, newCells := cells(o.Frames), cells(a.Frames)
+	var changed []int
+	for i, cell := range newCells {
+		if i >= len(oldCells) || o.Frame != a.Frame {
+			continue // already reported as a metadata change
+		}
+		from, to := rect(o, oldCells[i]), rect(a, cell)
+		if !samePixels(oldAtlas, from, atlas, to) {
+			changed = append(changed, i+1)
+		}
+	}
+	return changed
+}
+
+func samePixels(a image.Image, ar image.Rectangle, b image.Image, br image.Rectangle) bool {
+	for y := range ar.Dy() {
+		for x := range ar.Dx() {
+			p, q := image.Pt(ar.Min.X+x, ar.Min.Y+y), image.Pt(br.Min.X+x, br.Min.Y+y)
+			if !p.In(a.Bounds()) || !q.In(b.Bounds()) {
+				return false
+			}
+			r1, g1, b1, a1 := a.At(p.X, p.Y).RGBA()
+			r2, g2, b2, a2 := b.At(q.X, q.Y).RGBA()
+			if a1 == 0 && a2 == 0 {
+				continue // fully transparent in both, the color does not matter
+			}
+			if r1 != r2 || g1 != g2 || b1 != b2 || a1 != a2 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func composeAtlas(blocks []*block, width, height int) *image.NRGBA {
+	atlas := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for _, b := range blocks {
+		r := image.Rect(b.x, b.y, b.x+b.img.Bounds().Dx(), b.y+b.img.Bounds().Dy())
+		draw.Draw(atlas, r, b.img, b.img.Bounds().Min, draw.Src)
+	}
+	return atlas
+}
+
+func writeAtlas(path string, atlas image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, atlas); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeMeta(path string, meta *atlasMeta) error {
+	data, err := json.MarshalIndent(meta, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func newBlock(name string, w, h int) *block {
+	return &block{name: name, img: image.NewNRGBA(image.Rect(0, 0, w, h)), frameW: w, frameH: h, cols: 1, rows: 1}
+}
+
+func TestPack(t *testing.T) {
+	tests := []struct {
+		name   string
+		blocks []*block
+		width  int
+		want   map[string]image.Point
+		height int
+	}{
+		{
+			name:   "one shelf",
+			blocks: []*block{newBlock("a", 16, 16), newBlock("b", 16, 16)},
+			width:  64,
+			want:   map[string]image.Point{"a": {0, 0}, "b": {16, 0}},
+			height: 16,
+		},
+		{
+			name:   "tallest first then by name",
+			blocks: []*block{newBlock("a", 32, 16), newBlock("b", 32, 32), newBlock("c", 16, 16), newBlock("d", 64, 8)},
+			width:  64,
+			want:   map[string]image.Point{"b": {0, 0}, "a": {32, 0}, "c": {0, 32}, "d": {0, 48}},
+			height: 64, // 56 rounded up to whole tiles
+		},
+		{
+			name:   "full width",
+			blocks: []*block{newBlock("wide", 64, 4), newBlock("next", 8, 4)},
+			width:  64,
+			want:   map[string]image.Point{"next": {0, 0}, "wide": {0, 4}},
+			height: 16,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			height, err := pack(tt.blocks, tt.width)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if height != tt.height {
+				t.Errorf("height %d, want %d", height, tt.height)
+			}
+			for _, b := range tt.blocks {
+				if got := image.Pt(b.x, b.y); got != tt.want[b.name] {
+					t.Errorf("%s at %v, want %v", b.name, got, tt.want[b.name])
+				}
+			}
+		})
+	}
+
+	if _, err := pack([]*block{newBlock("huge", 65, 1)}, 64); err == nil {
+		t.Error("a block wider than the atlas was packed")
+	}
+}
+
+func TestFrames(t *testing.T) {
+	tests := []struct {
+		name       string
+		cols, rows int
+		order      []int
+		want       []interface{}
+	}{
+		{"row", 4, 1, nil, []interface{}{"1-4", 1}},
+		{"column", 1, 3, nil, []interface{}{1, "1-3"}},
+		{"grid", 2, 2, nil, []interface{}{"1-2", 1, "1-2", 2}},
+		{"single", 1, 1, nil, []interface{}{1, 1}},
+		{"reversed", 4, 1, []int{4, 3, 2, 1}, []interface{}{"4-1", 1}},
+		{"picked", 4, 1, []int{2}, []interface{}{2, 1}},
+		{"gaps", 4, 1, []int{1, 3}, []interface{}{1, 1, 3, 1}},
+		{"down the column", 2, 3, []int{2, 4, 6}, []interface{}{2, "1-3"}},
+		{"back and forth", 3, 1, []int{1, 2, 3, 2, 1}, []interface{}{"1-3", 1, "2-1", 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &block{cols: tt.cols, rows: tt.rows}
+			got := b.frames(tt.order)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("frames %v, want %v", got, tt.want)
+			}
+
+			//the ranges have to expand back to the frames they were made of
+			order := tt.order
+			if order == nil {
+				for i := range tt.cols * tt.rows {
+					order = append(order, i+1)
+				}
+			}
+			expanded := cells(got)
+			if len(expanded) != len(order) {
+				t.Fatalf("cells %v, want %d frames", expanded, len(order))
+			}
+			for i, f := range order {
+				want := [2]int{(f-1)%tt.cols + 1, (f-1)/tt.cols + 1}
+				if expanded[i] != want {
+					t.Errorf("frame %d expands to %v, want %v", i+1, expanded[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestCells(t *testing.T) {
+	tests := []struct {
+		frames []interface{}
+		want   [][2]int
+	}{
+		{[]interface{}{1, 1}, [][2]int{{1, 1}}},
+		{[]interface{}{float64(2), float64(3)}, [][2]int{{2, 3}}}, // numbers as read from JSON
+		{[]interface{}{"1-3", 2}, [][2]int{{1, 2}, {2, 2}, {3, 2}}},
+		{[]interface{}{1, "2-1"}, [][2]int{{1, 2}, {1, 1}}},
+		{[]interface{}{"1-2", "1-2"}, [][2]int{{1, 1}, {2, 1}, {1, 2}, {2, 2}}},
+	}
+
+	for _, tt := range tests {
+		if got := cells(tt.frames); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("cells(%v) = %v, want %v", tt.frames, got, tt.want)
+		}
+	}
+}
+
+func TestCheckPalette(t *testing.T) {
+	tests := []struct {
+		name string
+		c    color.Color
+		ok   bool
+	}{
+		{"black", color.Black, true},
+		{"white", color.White, true},
+		{"transparent", color.Transparent, true},
+		{"transparent with color", color.NRGBA{255, 0, 0, 0}, true},
+		{"gray", color.Gray{128}, false},
+		{"red", color.NRGBA{255, 0, 0, 255}, false},
+		{"half transparent black", color.NRGBA{0, 0, 0, 128}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBlock("b", 4, 4)
+			b.img.(*image.NRGBA).Set(2, 1, tt.c)
+			errs := checkPalette([]*block{b})
+			if ok := len(errs) == 0; ok != tt.ok {
+				t.Errorf("errors %v, want ok %v", errs, tt.ok)
+			}
+			if !tt.ok && len(errs) == 1 && !strings.Contains(errs[0].Error(), "1 pixels") {
+				t.Errorf("error %v does not count the one bad pixel", errs[0])
+			}
+		})
+	}
+}
+
+// strip is a 2 frame animation of 4x4 frames at origin on an atlas of size, frame 2 has one white pixel
+func strip(origin image.Point, size image.Point) (animationMeta, *image.NRGBA) {
+	img := image.NewNRGBA(image.Rectangle{Max: size})
+	img.Set(origin.X+4+1, origin.Y+2, color.White)
+	return animationMeta{Frame: [2]int{4, 4}, Origin: [2]int{origin.X, origin.Y}, Frames: []interface{}{"1-2", 1}}, img
+}
+
+func TestChangedFrames(t *testing.T) {
+	o, oldAtlas := strip(image.Pt(0, 0), image.Pt(16, 16))
+
+	t.Run("same", func(t *testing.T) {
+		a, atlas := strip(image.Pt(0, 0), image.Pt(16, 16))
+		if changed := changedFrames(o, a, oldAtlas, atlas); len(changed) > 0 {
+			t.Errorf("changed %v, want none", changed)
+		}
+	})
+
+	t.Run("moved", func(t *testing.T) {
+		a, atlas := strip(image.Pt(8, 4), image.Pt(16, 16))
+		if changed := changedFrames(o, a, oldAtlas, atlas); len(changed) > 0 {
+			t.Errorf("changed %v, want none", changed)
+		}
+	})
+
+	t.Run("pixel", func(t *testing.T) {
+		a, atlas := strip(image.Pt(0, 0), image.Pt(16, 16))
+		atlas.Set(1, 1, color.Black)
+		if changed := changedFrames(o, a, oldAtlas, atlas); fmt.Sprint(changed) != "[1]" {
+			t.Errorf("changed %v, want [1]", changed)
+		}
+	})
+
+	t.Run("color under transparency", func(t *testing.T) {
+		a, atlas := strip(image.Pt(0, 0), image.Pt(16, 16))
+		atlas.Set(1, 1, color.NRGBA{255, 0, 0, 0})
+		if changed := changedFrames(o, a, oldAtlas, atlas); len(changed) > 0 {
+			t.Errorf("changed %v, want none", changed)
+		}
+	})
+
+	t.Run("cut off", func(t *testing.T) {
+		a, atlas := strip(image.Pt(0, 0), image.Pt(6, 4))
+		if changed := changedFrames(o, a, oldAtlas, atlas); fmt.Sprint(changed) != "[2]" {
+			t.Errorf("changed %v, want [2]", changed)
+		}
+	})
+}
+
+func TestReport(t *testing.T) {
+	kept, atlas := strip(image.Pt(0, 0), image.Pt(16, 16))
+	moved := kept
+	moved.Origin = [2]int{8, 8}
+
+	old := &atlasMeta{Animations: map[string]animationMeta{"kept": kept, "moved": kept, "gone": kept}}
+	meta := &atlasMeta{Animations: map[string]animationMeta{"kept": kept, "moved": moved, "new": kept}}
+
+	out := captureStdout(t, func() { report(old, meta, nil, atlas) })
+	for _, want := range []string{
+		"atlas 16x16, 3 animations",
+		"  - gone\n",
+		"  ~ moved: moved [0 0] -> [8 8]\n",
+		"  + new 4x4 at [0 0]\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report misses %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "kept") {
+		t.Errorf("report lists an unchanged animation:\n%s", out)
+	}
+
+	if out := captureStdout(t, func() { report(meta, meta, atlas, atlas) }); !strings.Contains(out, "no changes") {
+		t.Errorf("report of the same atlas:\n%s", out)
+	}
+}
+
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	f()
+	w.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
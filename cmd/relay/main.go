@@ -0,0 +1,133 @@
+// Command relay is the lobby and relay server for online races. Two players
+// joining the same room are paired, given a seed and have their packets
+// forwarded to each other.
+//
+//	relay -addr :7777
+//
+// The games connect with -relay host:7777 -room name. With -selftest the relay
+// runs two simulated players against itself on loopback, over a link with the
+// given latency and loss, and checks both saw the same inputs:
+//
+//	relay -selftest -latency 80ms -jitter 20ms -loss 0.1
+package main
+
+import (
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math/rand"
+	"net"
+	"os"
+	"time"
+
+	"github.com/AndriiPets/1Bit/netplay"
+)
+
+func main() {
+	addr := flag.String("addr", ":7777", "UDP address to listen on")
+	delay := flag.Int("delay", netplay.DEFAULT_WAIT, "ticks of input delay handed to the races")
+	selftest := flag.Bool("selftest", false, "race two simulated players on loopback and exit")
+	latency := flag.Duration("latency", 0, "simulated one way latency of the relay and the simulated players")
+	jitter := flag.Duration("jitter", 0, "random extra latency up to this duration")
+	loss := flag.Float64("loss", 0, "share of packets dropped, 0 to 1")
+	ticks := flag.Int("ticks", 1200, "ticks the self test races for")
+	flag.Parse()
+
+	if *selftest {
+		if err := selfTest(*delay, *ticks, *latency, *jitter, *loss); err != nil {
+			log.Println("Self test failed:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	conn, err := net.ListenPacket("udp", *addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Relay listening on", conn.LocalAddr())
+
+	var pc net.PacketConn = conn
+	if *latency > 0 || *jitter > 0 || *loss > 0 {
+		pc = netplay.NewLossy(conn, *latency, *jitter, *loss)
+	}
+	log.Fatal(netplay.NewRelay(pc, *delay).Serve())
+}
+
+// selfTest plays both sides at 60 ticks a second with random inputs
+func selfTest(delay, ticks int, latency, jitter time.Duration, loss float64) error {
+	relayConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	defer relayConn.Close()
+	go netplay.NewRelay(netplay.NewLossy(relayConn, latency, jitter, loss), delay).Serve()
+
+	var sessions [netplay.PLAYERS]*netplay.Session
+	for i := range sessions {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			return err
+		}
+		s, err := netplay.Dial(netplay.NewLossy(conn, latency, jitter, loss), relayConn.LocalAddr().String(), netplay.Join{Room: "selftest"})
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+		sessions[i] = s
+	}
+
+	streams := [netplay.PLAYERS]hashWriter{newHash(), newHash()}
+	stalls := [netplay.PLAYERS]int{}
+	rng := rand.New(rand.NewSource(1))
+	began := time.Now()
+	deadline := began.Add(time.Duration(ticks)*time.Second/60 + 30*time.Second)
+
+	for frame := time.NewTicker(time.Second / 60); ; <-frame.C {
+		done := true
+		for i, s := range sessions {
+			s.Poll()
+			if s.Err != nil {
+				return fmt.Errorf("player %d: %w", i+1, s.Err)
+			}
+			if int(s.Tick) >= ticks {
+				continue
+			}
+			done = false
+
+			if !s.Advance(byte(rng.Intn(32))) {
+				if s.Started {
+					stalls[i]++
+				}
+				continue
+			}
+			streams[i].Write(s.Frame[:])
+			if s.Tick%60 == 0 {
+				s.Check(s.Tick, streams[i].Sum64())
+			}
+		}
+
+		if done {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("only reached ticks %d and %d", sessions[0].Tick, sessions[1].Tick)
+		}
+	}
+
+	if streams[0].Sum64() != streams[1].Sum64() {
+		return fmt.Errorf("the players saw different inputs")
+	}
+	log.Printf("%d ticks in %s, stalled %d and %d ticks, inputs agree", ticks, time.Since(began).Round(time.Millisecond), stalls[0], stalls[1])
+	return nil
+}
+
+type hashWriter interface {
+	Write([]byte) (int, error)
+	Sum64() uint64
+}
+
+func newHash() hashWriter {
+	return fnv.New64a()
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+const (
+	CONSOLE_LINES   = 12
+	CONSOLE_HISTORY = 64
+)
+
+type Command struct {
+	Usage string
+	Args  func(n int) []string // completion candidates for the nth argument, nil completes nothing
+	Run   func(g *Game, args []string) (string, error)
+	Cheat bool // changes the run, scores of the session are no longer saved
+}
+
+var onOff = func(n int) []string {
+	if n == 0 {
+		return []string{"on", "off"}
+	}
+	return nil
+}
+
+// Commands is filled in init since help reads it
+var Commands map[string]Command
+
+func init() {
+	Commands = map[string]Command{
+		"help": {Usage: "help", Run: func(*Game, []string) (string, error) {
+			var names []string
+			for name := range Commands {
+				names = append(names, Commands[name].Usage)
+			}
+			sort.Strings(names)
+			return strings.Join(names, "\n"), nil
+		}},
+		"speed": {Cheat: true, Usage: "speed <value>", Run: func(g *Game, args []string) (string, error) {
+			v, err := floatArg(args, 0)
+			if err != nil {
+				return "", err
+			}
+			GameSpeed = v
+			return fmt.Sprintf("speed %.2f", GameSpeed), nil
+		}},
+		"difficulty": {Cheat: true, Usage: "difficulty <level>", Run: func(g *Game, args []string) (string, error) {
+			v, err := intArg(args, 0)
+			if err != nil {
+				return "", err
+			}
+			Difficulty = v
+			return fmt.Sprintf("difficulty %d", Difficulty), nil
+		}},
+		"spawn": {Cheat: true, Usage: "spawn <type> <x> <y>", Args: func(n int) []string {
+			if n == 0 {
+				var names []string
+				for _, t := range PlatformTypes {
+					names = append(names, t.String())
+				}
+				return names
+			}
+			return nil
+		}, Run: func(g *Game, args []string) (string, error) {
+			var pType PlatformType = -1
+			for _, t := range PlatformTypes {
+				if len(args) > 0 && t.String() == args[0] {
+					pType = t
+				}
+			}
+			if pType < 0 {
+				return "", fmt.Errorf("unknown platform type")
+			}
+			x, err := floatArg(args, 1)
+			if err != nil {
+				return "", err
+			}
+			y, err := floatArg(args, 2)
+			if err != nil {
+				return "", err
+			}
+			g.platformSpawner.Spawn(Vec2{x, y}, pType, "platform")
+			return fmt.Sprintf("spawned %s at %.0f, %.0f", pType, x, y), nil
+		}},
+		"god": {Cheat: true, Usage: "god <on|off>", Args: onOff, Run: func(g *Game, args []string) (string, error) {
+			v, err := boolArg(args, 0)
+			if err != nil {
+				return "", err
+			}
+			g.player.God = v
+			return fmt.Sprintf("god %t", v), nil
+		}},
+		"seed": {Usage: "seed <number>", Run: func(g *Game, args []string) (string, error) {
+			v, err := intArg(args, 0)
+			if err != nil {
+				return "", err
+			}
+			g.seed, g.seeded = int64(v), true
+			g.Restart()
+			g.title = false
+			return fmt.Sprintf("restarted with seed %d", v), nil
+		}},
+		"mode": {Cheat: true, Usage: "mode <jumping|flying>", Args: func(n int) []string {
+			if n == 0 {
+				return []string{Jumping.String(), Flying.String()}
+			}
+			return nil
+		}, Run: func(g *Game, args []string) (string, error) {
+			switch {
+			case len(args) > 0 && args[0] == Jumping.String():
+				g.player.controls = Jumping
+			case len(args) > 0 && args[0] == Flying.String():
+				g.player.controls = Flying
+			default:
+				return "", fmt.Errorf("expected jumping or flying")
+			}
+			return "mode " + g.player.controls.String(), nil
+		}},
+		"sweep": {Cheat: true, Usage: "sweep", Run: func(g *Game, _ []string) (string, error) {
+			g.platformSpawner.Sweep()
+			return "platforms removed", nil
+		}},
+	}
+}
+
+func floatArg(args []string, i int) (float64, error) {
+	if i >= len(args) {
+		return 0, fmt.Errorf("missing argument %d", i+1)
+	}
+	return strconv.ParseFloat(args[i], 64)
+}
+
+func intArg(args []string, i int) (int, error) {
+	if i >= len(args) {
+		return 0, fmt.Errorf("missing argument %d", i+1)
+	}
+	return strconv.Atoi(args[i])
+}
+
+func boolArg(args []string, i int) (bool, error) {
+	if i >= len(args) {
+		return false, fmt.Errorf("missing argument %d", i+1)
+	}
+	switch args[i] {
+	case "on", "1", "true":
+		return true, nil
+	case "off", "0", "false":
+		return false, nil
+	}
+	return false, fmt.Errorf("expected on or off")
+}
+
+// Console drops down with the key left of 1, the game does not update while it is open
+type Console struct {
+	Open    bool
+	input   []rune
+	log     []string
+	history []string
+	recall  int // position in history while browsing with up and down
+}
+
+func NewConsole() *Console {
+	return &Console{log: []string{"type help for a list of commands"}}
+}
+
+func (c *Console) print(s string) {
+	c.log = append(c.log, strings.Split(s, "\n")...)
+	if len(c.log) > CONSOLE_HISTORY {
+		c.log = c.log[len(c.log)-CONSOLE_HISTORY:]
+	}
+}
+
+// Execute runs one line, it is also how commands can be scripted
+func (c *Console) Execute(g *Game, line string) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return
+	}
+	c.print("> " + line)
+
+	cmd, ok := Commands[fields[0]]
+	if !ok {
+		c.print("unknown command " + fields[0])
+		return
+	}
+
+	out, err := cmd.Run(g, fields[1:])
+	if err != nil {
+		c.print(fmt.Sprintf("%s: %v, usage: %s", fields[0], err, cmd.Usage))
+		return
+	}
+	if cmd.Cheat {
+		g.cheat()
+	}
+	c.print(out)
+}
+
+// complete fills in the longest common prefix of the candidates for the word being typed
+func (c *Console) complete() {
+	line := string(c.input)
+	fields := strings.Fields(line)
+	if len(fields) == 0 || strings.HasSuffix(line, " ") {
+		fields = append(fields, "")
+	}
+	word := fields[len(fields)-1]
+
+	var candidates []string
+	if len(fields) == 1 {
+		for name := range Commands {
+			candidates = append(candidates, name)
+		}
+	} else if cmd, ok := Commands[fields[0]]; ok && cmd.Args != nil {
+		candidates = cmd.Args(len(fields) - 2)
+	}
+
+	var matches []string
+	for _, s := range candidates {
+		if strings.HasPrefix(s, word) {
+			matches = append(matches, s)
+		}
+	}
+	if len(matches) == 0 {
+		return
+	}
+	sort.Strings(matches)
+
+	prefix := matches[0]
+	for _, m := range matches[1:] {
+		for !strings.HasPrefix(m, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	if len(matches) == 1 {
+		prefix += " "
+	} else if prefix == word {
+		c.print(strings.Join(matches, " "))
+	}
+
+	c.input = []rune(strings.TrimSuffix(line, word) + prefix)
+}
+
+func (c *Console) Update(g *Game) {
+	if inpututil.IsKeyJustPressed(ebiten.KeyGraveAccent) {
+		c.Open = !c.Open
+		c.input = c.input[:0]
+		return
+	}
+	if !c.Open {
+		return
+	}
+
+	for _, r := range ebiten.AppendInputChars(nil) {
+		if r != '`' && r != '~' {
+			c.input = append(c.input, r)
+		}
+	}
+
+	repeat := func(k ebiten.Key) bool {
+		d := inpututil.KeyPressDuration(k)
+		return d == 1 || d > 30 && d%3 == 0
+	}
+
+	switch {
+	case repeat(ebiten.KeyBackspace) && len(c.input) > 0:
+		c.input = c.input[:len(c.input)-1]
+
+	case inpututil.IsKeyJustPressed(ebiten.KeyTab):
+		c.complete()
+
+	case inpututil.IsKeyJustPressed(ebiten.KeyUp) && len(c.history) > 0:
+		c.recall = max(0, c.recall-1)
+		c.input = []rune(c.history[c.recall])
+
+	case inpututil.IsKeyJustPressed(ebiten.KeyDown) && len(c.history) > 0:
+		c.recall = min(len(c.history), c.recall+1)
+		c.input = c.input[:0]
+		if c.recall < len(c.history) {
+			c.input = []rune(c.history[c.recall])
+		}
+
+	case inpututil.IsKeyJustPressed(ebiten.KeyEnter):
+		line := strings.TrimSpace(string(c.input))
+		if line != "" {
+			c.history = append(c.history, line)
+			if len(c.history) > CONSOLE_HISTORY {
+				c.history = c.history[1:]
+			}
+		}
+		c.recall = len(c.history)
+		c.input = c.input[:0]
+		c.Execute(g, line)
+
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		c.Open = false
+	}
+}
+
+func (c *Console) Draw(screen *ebiten.Image) {
+	if !c.Open {
+		return
+	}
+
+	h := lineHeight(Font)
+	vector.DrawFilledRect(screen, 0, 0, SCREEN_WIDTH, float32(h*(CONSOLE_LINES+1)+8), color.RGBA{0, 0, 0, 200}, false)
+
+	lines := c.log[max(0, len(c.log)-CONSOLE_LINES):]
+	y := 4 + (CONSOLE_LINES-len(lines))*h
+	for _, l := range lines {
+		drawString(screen, l, Font, image.Point{4, y}, color.White)
+		y += h
+	}
+	drawString(screen, "] "+string(c.input)+"_", Font, image.Point{4, y}, color.White)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	dailyFile  = "daily.json"
+	DAILY_BEST = 5 // days listed in the high score table
+)
+
+// DailyProfile scales the tuning for one day of the week
+type DailyProfile struct {
+	Name  string
+	Speed float64 // start speed
+	Step  float64 // speed gained per difficulty level
+	Waves float64 // platforms per wave
+}
+
+var dailyProfiles = map[time.Weekday]DailyProfile{
+	time.Monday:    {Name: "gentle", Speed: 0.8, Step: 0.8, Waves: 1.2},
+	time.Tuesday:   {Name: "steady", Speed: 1, Step: 1, Waves: 1},
+	time.Wednesday: {Name: "sparse", Speed: 1, Step: 1, Waves: 0.7},
+	time.Thursday:  {Name: "quick", Speed: 1.3, Step: 0.9, Waves: 1},
+	time.Friday:    {Name: "crowded", Speed: 1, Step: 1.1, Waves: 1.4},
+	time.Saturday:  {Name: "steep", Speed: 1.1, Step: 1.5, Waves: 1},
+	time.Sunday:    {Name: "brutal", Speed: 1.4, Step: 1.3, Waves: 0.8},
+}
+
+func (p DailyProfile) apply(t TuningValues) TuningValues {
+	t.StartSpeed *= p.Speed
+	t.SpeedStep *= p.Step
+	t.WaveSize = max(1, int(math.Round(float64(t.WaveSize)*p.Waves)))
+	return t
+}
+
+// DailyRun is today's tower, the same for everyone playing on the same UTC date
+type DailyRun struct {
+	Date    string
+	Seed    int64
+	Profile DailyProfile
+	tuning  TuningValues // restored when the run ends
+	started bool         // any restart from here on leaves the attempt
+}
+
+func NewDailyRun(now time.Time) *DailyRun {
+	now = now.UTC()
+	h := fnv.New64a()
+	h.Write([]byte("hextower-daily-" + now.Format(time.DateOnly)))
+
+	return &DailyRun{
+		Date:    now.Format(time.DateOnly),
+		Seed:    int64(h.Sum64() >> 1),
+		Profile: dailyProfiles[now.Weekday()],
+	}
+}
+
+type DailyResult struct {
+	Date    string `json:"date"`
+	Profile string `json:"profile"`
+	Mode    string `json:"mode"`
+	Score   int    `json:"score"`
+	Done    bool   `json:"done"` // false while the attempt is running, a quit attempt still counts
+}
+
+// DailyTable is kept apart from the other high scores
+type DailyTable struct {
+	Results []DailyResult `json:"results"`
+}
+
+func LoadDailyTable() *DailyTable {
+	t := &DailyTable{}
+	b, err := loadData(dailyFile)
+	if err != nil || b == nil {
+		return t
+	}
+	if err := json.Unmarshal(b, t); err != nil {
+		log.Println("Daily results are corrupted:", err)
+		return &DailyTable{}
+	}
+	return t
+}
+
+func (t *DailyTable) Save() {
+	b, err := json.MarshalIndent(t, "", "\t")
+	if err == nil {
+		err = saveData(dailyFile, b)
+	}
+	if err != nil {
+		log.Println("Cannot save daily results:", err)
+	}
+}
+
+func (t *DailyTable) Find(date string) *DailyResult {
+	for i := range t.Results {
+		if t.Results[i].Date == date {
+			return &t.Results[i]
+		}
+	}
+	return nil
+}
+
+// Best lists the highest scoring days first
+func (t *DailyTable) Best(n int) []DailyResult {
+	best := append([]DailyResult(nil), t.Results...)
+	sort.SliceStable(best, func(i, j int) bool { return best[i].Score > best[j].Score })
+	return best[:min(n, len(best))]
+}
+
+// Share is a line to paste to friends, the code makes typos in the score obvious
+func (r DailyResult) Share() string {
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%s|%s|%s|%d", r.Date, r.Profile, r.Mode, r.Score)
+	return fmt.Sprintf("HEXTOWER daily %s %s %s: %d #%04X", r.Date, r.Profile, r.Mode, r.Score, h.Sum32()&0xFFFF)
+}
+
+// startDaily uses up today's attempt, or shows the result when it was already played
+func (g *Game) startDaily() {
+	daily := NewDailyRun(time.Now())
+	g.dailyTable = LoadDailyTable()
+
+	if g.dailyTable.Find(daily.Date) != nil {
+		g.showDaily = true
+		return
+	}
+
+	g.dailyTable.Results = append(g.dailyTable.Results, DailyResult{
+		Date:    daily.Date,
+		Profile: daily.Profile.Name,
+		Mode:    g.settings.Mode.String(),
+	})
+	g.dailyTable.Save()
+
+	daily.tuning = Tuning
+	Tuning = daily.Profile.apply(Tuning)
+	g.daily = daily
+	g.seed, g.seeded = daily.Seed, true
+	g.Restart()
+	g.title = false
+	daily.started = true
+}
+
+// finishDaily scores the attempt once the run is over, a session with console cheats scores nothing
+func (g *Game) finishDaily() {
+	r := g.dailyTable.Find(g.daily.Date)
+	if r == nil || r.Done {
+		return
+	}
+	if !g.cheated {
+		r.Score = int(g.score)
+	}
+	r.Done = true
+	g.dailyTable.Save()
+	g.showDaily = true
+	log.Println(r.Share())
+}
+
+// leaveDaily closes the attempt however the run is left, an unfinished one is scored as it stands
+func (g *Game) leaveDaily() {
+	g.finishDaily()
+	Tuning = g.daily.tuning
+	if g.seed == g.daily.Seed {
+		g.seeded = false // a seed picked in the console stays
+	}
+	g.daily = nil
+	g.showDaily = true
+}
+
+// NewDailyResults shows today's attempt, its share line and the best days so far
+func NewDailyResults(g *Game) Widget {
+	today := func() *DailyResult {
+		return g.dailyTable.Find(NewDailyRun(time.Now()).Date)
+	}
+
+	rows := []Widget{
+		NewLabel(func() string {
+			if r := today(); r != nil {
+				return T("daily_today", "date", r.Date, "profile", T("daily_"+r.Profile), "score", Lang.FormatNumber(r.Score))
+			}
+			return ""
+		}, false),
+		NewLabel(func() string {
+			if r := today(); r != nil && r.Done {
+				return r.Share()
+			}
+			return ""
+		}, false),
+		NewLabel(Msg("daily_best"), false),
+	}
+	for i := range DAILY_BEST {
+		rows = append(rows, NewLabel(func() string {
+			best := g.dailyTable.Best(DAILY_BEST)
+			if i >= len(best) {
+				return ""
+			}
+			r := best[i]
+			return fmt.Sprintf("%d. %s  %s  %s", i+1, r.Date, T("daily_"+r.Profile), Lang.FormatNumber(r.Score))
+		}, false))
+	}
+	return NewList(0, rows...)
+}
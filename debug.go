@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+var layerNames = map[LayerID]string{
+	Background:  "background",
+	BehindTower: "behind",
+	Tower:       "tower",
+	BeforeTower: "before",
+	UI:          "ui",
+	Invisible:   "invisible",
+}
+
+func (l LayerID) String() string {
+	return layerNames[l]
+}
+
+// layerColor tells the layers apart in the debug outlines
+func layerColor(l LayerID) color.Color {
+	switch l {
+	case BehindTower:
+		return color.RGBA{0, 128, 255, 255}
+	case BeforeTower:
+		return color.RGBA{0, 255, 0, 255}
+	case Invisible:
+		return color.RGBA{128, 128, 128, 255}
+	default:
+		return color.RGBA{255, 0, 255, 255}
+	}
+}
+
+// DebugOverlay is toggled with F1, it draws metrics over the canvas and outlines into the world
+type DebugOverlay struct {
+	game      *Game
+	hud       *UIScreen
+	lastFrame time.Time
+	frameTime time.Duration
+}
+
+func NewDebugOverlay(g *Game) *DebugOverlay {
+	d := &DebugOverlay{game: g}
+
+	lines := []Text{
+		func() string {
+			return fmt.Sprintf("FPS: %.1f TPS: %.1f Frame: %.2fms", ebiten.ActualFPS(), ebiten.ActualTPS(), float64(d.frameTime.Microseconds())/1000)
+		},
+		func() string {
+			used := 0
+			for _, p := range g.platformSpawner.Platforms {
+				if p != nil && p.used {
+					used++
+				}
+			}
+			return fmt.Sprintf("Spawner slots: %d/%d", used, len(g.platformSpawner.Platforms))
+		},
+		d.spritesPerLayer,
+		func() string { return fmt.Sprintf("GameSpeed: %.2f Difficulty: %d", GameSpeed, Difficulty) },
+		func() string {
+			return fmt.Sprintf("Velocity: %.2f, %.2f OnGround: %t", g.player.Speed.X, g.player.Speed.Y, g.player.OnGround != nil)
+		},
+		func() string { return g.camera.String() },
+		func() string {
+			x, y := g.mouseWorld()
+			return fmt.Sprintf("Cursor World Pos: %.2f, %.2f", x, y)
+		},
+	}
+
+	panel := NewPanel(AnchorBottomLeft, image.Point{0, 0})
+	for _, l := range lines {
+		panel.Children = append(panel.Children, NewLabel(l, false))
+	}
+	panel.Spacing = 0
+	panel.Background = color.RGBA{0, 0, 0, 160}
+	d.hud = NewUIScreen(panel)
+
+	return d
+}
+
+func (d *DebugOverlay) spritesPerLayer() string {
+	counts := make(map[LayerID]int)
+	for _, s := range d.game.sprites {
+		counts[s.Layer]++
+	}
+
+	var parts []string
+	for l := Background; l <= Invisible; l++ {
+		if counts[l] > 0 {
+			parts = append(parts, fmt.Sprintf("%s %d", l, counts[l]))
+		}
+	}
+	return "Sprites: " + strings.Join(parts, ", ")
+}
+
+// mouseWorld goes from window pixels through the canvas to the world image
+func (g *Game) mouseWorld() (float64, float64) {
+	x, y := g.renderer.ScreenToCanvas(ebiten.CursorPosition())
+	return g.camera.ScreenToWorld(x, y)
+}
+
+// DrawWorld outlines the resolv grid and every object, in world coordinates
+func (d *DebugOverlay) DrawWorld(world *ebiten.Image) {
+	d.game.DebugDraw(world)
+
+	for _, s := range d.game.sprites {
+		if s.Object == nil {
+			continue
+		}
+		obj := s.Object
+		vector.StrokeRect(world, float32(obj.Position.X), float32(obj.Position.Y), float32(obj.Size.X), float32(obj.Size.Y), 1, layerColor(s.Layer), false)
+	}
+}
+
+// Draw puts the metrics on the canvas, frame time is measured between two calls
+func (d *DebugOverlay) Draw(screen *ebiten.Image) {
+	now := time.Now()
+	if !d.lastFrame.IsZero() {
+		d.frameTime = now.Sub(d.lastFrame)
+	}
+	d.lastFrame = now
+
+	d.hud.Draw(screen)
+}
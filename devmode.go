@@ -0,0 +1,117 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"time"
+)
+
+//go:embed assets/*
+var embedded embed.FS
+
+// Assets is where every asset is read from, the embedded files unless dev mode points it at a folder on disk
+var Assets fs.FS = embedded
+
+// Watcher polls files for changes, fs.FS has no change notifications
+type Watcher struct {
+	fsys  fs.FS
+	files map[string]time.Time
+	ticks int
+}
+
+func NewWatcher(fsys fs.FS, files ...string) *Watcher {
+	w := &Watcher{fsys: fsys, files: make(map[string]time.Time)}
+	for _, f := range files {
+		w.files[f] = w.modTime(f)
+	}
+	return w
+}
+
+func (w *Watcher) modTime(file string) time.Time {
+	info, err := fs.Stat(w.fsys, file)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// Changed returns the files modified since the last check, it only looks twice a second
+func (w *Watcher) Changed() []string {
+	w.ticks++
+	if w.ticks%30 != 0 {
+		return nil
+	}
+
+	var changed []string
+	for f, last := range w.files {
+		if t := w.modTime(f); !t.Equal(last) {
+			w.files[f] = t
+			changed = append(changed, f)
+		}
+	}
+	return changed
+}
+
+func devFiles() []string {
+	return []string{
+		"assets/atlas.json",
+		Animations.Image,
+		"assets/platforms.json",
+		"assets/tuning.json",
+	}
+}
+
+// tryLoad turns the panic of a loader hitting a broken file into an error,
+// files are often caught half written while being saved
+func tryLoad(load func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return load()
+}
+
+func (g *Game) hotReload() {
+	for _, file := range g.watcher.Changed() {
+		var err error
+
+		switch file {
+		case "assets/platforms.json":
+			err = tryLoad(loadPlatformDefs)
+		case "assets/tuning.json":
+			err = tryLoad(loadTuning)
+		default:
+			err = tryLoad(func() error {
+				if err := loadAtlasAssets(); err != nil {
+					return err
+				}
+				g.reloadAnimations()
+				return nil
+			})
+		}
+
+		if err != nil {
+			log.Println("Reload failed:", file, err)
+			continue
+		}
+		log.Println("Reloaded", file)
+	}
+}
+
+// reloadAnimations rebuilds the animations of everything alive on the new atlas
+func (g *Game) reloadAnimations() {
+	for _, v := range g.views {
+		v.Background.SetupAnimation()
+		v.Player.Sprite.Animation = animationOrBlank("player")
+	}
+	g.particles.setupEmitters()
+
+	for _, p := range g.platformSpawner.Platforms {
+		if p != nil && p.used {
+			p.Sprite.Animation = animationOrBlank(PlatformDefs[p.kind].Animation)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// assetsRoot is where the editor writes chunks, the folder given with -assets.
+// The editor only opens in dev mode where the assets are read from there too.
+var assetsRoot = "."
+
+// Editor shows the tower unrolled so chunks can be edited in the coordinates the objects use.
+// F3 opens and closes it, T test-plays from the cursor and F3 comes back from the test.
+type Editor struct {
+	game     *Game
+	Active   bool
+	playing  bool
+	chunk    *Chunk
+	index    int // position in Chunks, len(Chunks) for a new chunk
+	selected int // platform in the chunk, -1 for none
+	dragging bool
+	pathMode bool
+	pType    PlatformType
+	focus    Vec2
+	ui       *UIScreen
+}
+
+func NewEditor(g *Game) *Editor {
+	e := &Editor{game: g, selected: -1}
+
+	help := NewPanel(AnchorBottomLeft, image.Point{0, 0},
+		NewLabel(func() string {
+			path := "off"
+			if e.pathMode {
+				path = "on"
+			}
+			return fmt.Sprintf("chunk %s  place %s  path edit %s", e.chunk.Name, e.pType, path)
+		}, false),
+		NewLabel(Static("LMB place/move  RMB delete  1-9 type  P path  BKSP undo point"), false),
+		NewLabel(Static("arrows pan  wheel zoom  PGUP/PGDN chunk  T test  CTRL+S save  F3 exit"), false),
+	)
+	help.Spacing = 0
+	help.Background = color.RGBA{0, 0, 0, 180}
+	help.Visible = func() bool { return !e.playing }
+	e.ui = NewUIScreen(help)
+
+	return e
+}
+
+func (e *Editor) Toggle() {
+	g := e.game
+	switch {
+	case e.playing:
+		e.playing = false
+		e.rebuild()
+	case e.Active:
+		e.Active = false
+		g.platformSpawner.Sweep()
+		g.Restart()
+		g.title = true
+	default:
+		e.Active = true
+		e.focus = Vec2{TOWER_OFFSET + TOWER_BOUNDS/2, HALF_HEIGHT}
+		e.open(min(e.index, len(Chunks)))
+	}
+}
+
+// open loads a copy of a chunk, len(Chunks) starts a new one
+func (e *Editor) open(index int) {
+	e.index = index
+	e.selected = -1
+	e.pathMode = false
+
+	if index < len(Chunks) {
+		c := *Chunks[index]
+		c.Platforms = append([]ChunkPlatform(nil), c.Platforms...)
+		for i := range c.Platforms {
+			c.Platforms[i].Path = append([]float64(nil), c.Platforms[i].Path...)
+		}
+		e.chunk = &c
+	} else {
+		e.chunk = &Chunk{Name: fmt.Sprintf("chunk%d", len(Chunks)+1), Weight: 1}
+	}
+	e.rebuild()
+}
+
+// rebuild replaces the spawned platforms with the ones of the chunk
+func (e *Editor) rebuild() {
+	g := e.game
+	g.platformSpawner.Sweep()
+	g.platformSpawner.SpawnChunk(e.chunk)
+	g.title = false
+	GameSpeed = 0
+}
+
+func snap(v float64) float64 {
+	return math.Floor(v/TILE_SIZE) * TILE_SIZE
+}
+
+func (e *Editor) rect(p ChunkPlatform) (float64, float64, float64, float64) {
+	pType, _ := platformTypeByName(p.Type)
+	size := PlatformDefs[pType].Size
+	return p.Pos[0], p.Pos[1], size[0], size[1]
+}
+
+func (e *Editor) platformAt(x, y float64) int {
+	for i := len(e.chunk.Platforms) - 1; i >= 0; i-- {
+		px, py, w, h := e.rect(e.chunk.Platforms[i])
+		if x >= px && x < px+w && y >= py && y < py+h {
+			return i
+		}
+	}
+	return -1
+}
+
+func (e *Editor) save() {
+	b, err := e.chunk.Encode()
+	if err == nil {
+		file := filepath.Join(assetsRoot, filepath.FromSlash(chunksDir), e.chunk.Name+".json")
+		if err = os.MkdirAll(filepath.Dir(file), 0o755); err == nil {
+			err = os.WriteFile(file, b, 0o644)
+		}
+	}
+	if err != nil {
+		log.Println("Cannot save chunk:", err)
+		return
+	}
+	log.Println("Saved chunk", e.chunk.Name)
+
+	if err := loadChunks(); err != nil {
+		log.Println("Chunks failed to reload:", err)
+	}
+	for i, c := range Chunks {
+		if c.Name == e.chunk.Name {
+			e.index = i
+		}
+	}
+}
+
+// test starts a run from the cursor with the chunk platforms in place, like a console cheat
+// it keeps the session's scores from being saved
+func (e *Editor) test(at Vec2) {
+	g := e.game
+	g.cheat()
+	e.rebuild()
+	e.playing = true
+	e.dragging = false
+
+	g.player.Object.Position.X = at[0]
+	g.player.Object.Position.Y = at[1]
+	g.player.Speed.X, g.player.Speed.Y = 0, 0
+	g.player.dead = false
+	g.player.Object.Update()
+	GameSpeed = Tuning.StartSpeed
+}
+
+func (e *Editor) Update() {
+	g := e.game
+	mx, my := g.mouseWorld()
+	cursor := Vec2{snap(mx), snap(my)}
+
+	const pan = 6
+	if ebiten.IsKeyPressed(ebiten.KeyLeft) {
+		e.focus[0] -= pan
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyRight) {
+		e.focus[0] += pan
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyUp) {
+		e.focus[1] -= pan
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyDown) {
+		e.focus[1] += pan
+	}
+	if _, wheel := ebiten.Wheel(); wheel != 0 {
+		g.camera.SetZoomPreset(g.camera.zoomPreset + int(math.Copysign(1, wheel)))
+	}
+	g.camera.Update(e.focus, 0)
+
+	for i, t := range PlatformTypes {
+		if i < 9 && inpututil.IsKeyJustPressed(ebiten.Key1+ebiten.Key(i)) {
+			e.pType = t
+		}
+	}
+
+	ctrl := ebiten.IsKeyPressed(ebiten.KeyControl)
+	switch {
+	case ctrl && inpututil.IsKeyJustPressed(ebiten.KeyS):
+		e.save()
+		return
+	case inpututil.IsKeyJustPressed(ebiten.KeyPageUp):
+		e.open((e.index + len(Chunks)) % (len(Chunks) + 1))
+		return
+	case inpututil.IsKeyJustPressed(ebiten.KeyPageDown):
+		e.open((e.index + 1) % (len(Chunks) + 1))
+		return
+	case inpututil.IsKeyJustPressed(ebiten.KeyT):
+		e.test(cursor)
+		return
+	case inpututil.IsKeyJustPressed(ebiten.KeyP) && e.selected >= 0:
+		e.pathMode = !e.pathMode
+	}
+
+	if e.pathMode && e.selected >= 0 {
+		e.editPath(cursor)
+		return
+	}
+
+	changed := false
+	switch {
+	case inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft):
+		e.selected = e.platformAt(mx, my)
+		if e.selected < 0 {
+			e.chunk.Platforms = append(e.chunk.Platforms, ChunkPlatform{Type: e.pType.String(), Pos: cursor})
+			e.selected = len(e.chunk.Platforms) - 1
+			changed = true
+		}
+		e.dragging = true
+
+	case e.dragging && ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft):
+		if p := &e.chunk.Platforms[e.selected]; p.Pos != cursor {
+			p.Pos = cursor
+			changed = true
+		}
+
+	case e.dragging:
+		e.dragging = false
+
+	case inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonRight):
+		if i := e.platformAt(mx, my); i >= 0 {
+			e.chunk.Platforms = append(e.chunk.Platforms[:i], e.chunk.Platforms[i+1:]...)
+			e.selected = -1
+			changed = true
+		}
+	}
+
+	if changed {
+		e.rebuild()
+	}
+}
+
+// editPath adds the clicked columns to the path of the selected platform as offsets from its position
+func (e *Editor) editPath(cursor Vec2) {
+	p := &e.chunk.Platforms[e.selected]
+
+	switch {
+	case inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft):
+		if len(p.Path) == 0 {
+			p.Path = []float64{0}
+		}
+		p.Path = append(p.Path, cursor[0]-p.Pos[0])
+		e.rebuild()
+
+	case inpututil.IsKeyJustPressed(ebiten.KeyBackspace) && len(p.Path) > 0:
+		p.Path = p.Path[:len(p.Path)-1]
+		if len(p.Path) == 0 {
+			p.Path = nil
+		}
+		e.rebuild()
+	}
+}
+
+// DrawWorld draws the chunk flat, without the tower projection
+func (e *Editor) DrawWorld(world *ebiten.Image) {
+	world.Fill(color.RGBA{16, 16, 16, 255})
+
+	gridColor := color.RGBA{48, 48, 48, 255}
+	for x := TOWER_OFFSET; x <= TOWER_OFFSET+TOWER_BOUNDS; x += TILE_SIZE {
+		vector.StrokeLine(world, float32(x), 0, float32(x), SCREEN_HEIGHT, 1, gridColor, false)
+	}
+	for y := 0; y <= SCREEN_HEIGHT; y += TILE_SIZE {
+		vector.StrokeLine(world, TOWER_OFFSET, float32(y), TOWER_OFFSET+TOWER_BOUNDS, float32(y), 1, gridColor, false)
+	}
+
+	for i, p := range e.chunk.Platforms {
+		x, y, w, h := e.rect(p)
+		pType, _ := platformTypeByName(p.Type)
+
+		if frames, err := AtlasFrames(PlatformDefs[pType].Animation); err == nil && len(frames) > 0 {
+			op := &ebiten.DrawImageOptions{}
+			op.GeoM.Translate(x, y)
+			world.DrawImage(Atlas.SubImage(*frames[0]).(*ebiten.Image), op)
+		}
+
+		path := p.Path
+		if path == nil {
+			path = PlatformDefs[pType].Path
+		}
+		for j := 1; j < len(path); j++ {
+			x0, x1 := float32(x+path[j-1]), float32(x+path[j])
+			vector.StrokeLine(world, x0+float32(w)/2, float32(y+h/2), x1+float32(w)/2, float32(y+h/2), 1, color.RGBA{255, 255, 0, 255}, false)
+			vector.StrokeRect(world, x1, float32(y), float32(w), float32(h), 1, color.RGBA{255, 255, 0, 128}, false)
+		}
+
+		if i == e.selected {
+			vector.StrokeRect(world, float32(x)-1, float32(y)-1, float32(w)+2, float32(h)+2, 1, color.White, false)
+		}
+	}
+}
+
+func (e *Editor) Draw(screen *ebiten.Image) {
+	e.ui.Draw(screen)
+}
@@ -0,0 +1,99 @@
+package main
+
+import "reflect"
+
+// events published on the game's bus. Payloads are copies: players and platforms are named by their slot
+// and carry the few values subscribers need, so a subscriber cannot reach back into the simulation
+
+type PlayerDied struct {
+	Player   int  // position in the party
+	Local    bool // the player sitting at this machine
+	Pos      Vec2 // center of the player
+	Platform PlatformType
+}
+
+type DifficultyRaised struct {
+	Level int
+}
+
+type PlatformSpawned struct {
+	Slot int
+	Type PlatformType
+	Pos  Vec2
+}
+
+// PlatformReleased is published when a platform leaves the world, Passed is false when a sweep cleared it
+type PlatformReleased struct {
+	Slot   int
+	Type   PlatformType
+	Passed bool
+}
+
+type RunStarted struct {
+	Seed    int64
+	Online  bool
+	Mode    ControlMode
+	Players int
+	Start   Vec2 // where the local player starts
+}
+
+// RunEnded is published once every player is out, or one is left in versus
+type RunEnded struct {
+	Score      int
+	Difficulty int
+}
+
+// NearMiss is published when a platform passes a player within Tuning.NearMissMargin without touching
+type NearMiss struct {
+	Player int
+	Local  bool
+	Pos    Vec2 // center of the player
+	Combo  int
+	Bonus  float64
+}
+
+type ScoreMilestone struct {
+	Score int
+}
+
+// Ticked is published after every simulated tick with what the local player did
+type Ticked struct {
+	Playing bool // a run is going on, false on the title and once it is over
+	Local   PlayerState
+}
+
+type PlayerState struct {
+	Pos      Vec2
+	OnGround bool
+	Dead     bool
+	Left     bool // left is held
+}
+
+// Cheated is published when a console cheat or the editor makes the session stop counting
+type Cheated struct{}
+
+const SCORE_MILESTONE = 100 // score between two ScoreMilestone events
+
+// Bus hands each event to the subscribers of its type in the order they subscribed
+type Bus struct {
+	handlers map[reflect.Type][]any
+}
+
+func NewBus() *Bus {
+	return &Bus{handlers: map[reflect.Type][]any{}}
+}
+
+func Subscribe[E any](b *Bus, fn func(E)) {
+	t := reflect.TypeFor[E]()
+	b.handlers[t] = append(b.handlers[t], fn)
+}
+
+// Publish calls the subscribers right away, a nil bus drops the event
+func Publish[E any](b *Bus, e E) {
+	if b == nil {
+		return
+	}
+	for _, fn := range b.handlers[reflect.TypeFor[E]()] {
+		fn.(func(E))(e)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"image"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// fallbackFace draws the runes its main face lacks with a second face of the same size
+type fallbackFace struct {
+	font.Face
+	fallback font.Face
+	has      func(r rune) bool
+}
+
+func (f *fallbackFace) pick(r rune) font.Face {
+	if f.has(r) {
+		return f.Face
+	}
+	return f.fallback
+}
+
+func (f *fallbackFace) Glyph(dot fixed.Point26_6, r rune) (image.Rectangle, image.Image, image.Point, fixed.Int26_6, bool) {
+	return f.pick(r).Glyph(dot, r)
+}
+
+func (f *fallbackFace) GlyphBounds(r rune) (fixed.Rectangle26_6, fixed.Int26_6, bool) {
+	return f.pick(r).GlyphBounds(r)
+}
+
+func (f *fallbackFace) GlyphAdvance(r rune) (fixed.Int26_6, bool) {
+	return f.pick(r).GlyphAdvance(r)
+}
+
+func (f *fallbackFace) Kern(r0, r1 rune) fixed.Int26_6 {
+	if f.has(r0) && f.has(r1) {
+		return f.Face.Kern(r0, r1)
+	}
+	return 0
+}
+
+func (f *fallbackFace) Close() error {
+	f.fallback.Close()
+	return f.Face.Close()
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const REPLAY_VERSION = 1
+
+// Replay is a recorded run, one frame per tick holding the player position and the score
+type Replay struct {
+	Version int          `json:"version"`
+	Mode    string       `json:"mode"`
+	Seed    int64        `json:"seed"`
+	Seeded  bool         `json:"seeded"` // random runs still race the best random run
+	Score   float64      `json:"score"`
+	Frames  [][3]float32 `json:"frames"`
+}
+
+func NewReplay(g *Game) *Replay {
+	return &Replay{
+		Version: REPLAY_VERSION,
+		Mode:    g.player.controls.String(),
+		Seed:    g.seed,
+		Seeded:  g.seeded,
+	}
+}
+
+// Key groups the runs a ghost can be raced in
+func (r *Replay) Key() string {
+	if !r.Seeded {
+		return r.Mode + "-random"
+	}
+	return fmt.Sprintf("%s-%d", r.Mode, r.Seed)
+}
+
+func (r *Replay) Record(g *Game) {
+	round := func(v float64) float32 { return float32(math.Round(v*10) / 10) }
+	pos := g.player.Object.Position
+	r.Frames = append(r.Frames, [3]float32{round(pos.X), round(pos.Y), round(g.score)})
+}
+
+func ParseReplay(b []byte) (*Replay, error) {
+	r := &Replay{}
+	if err := json.Unmarshal(b, r); err != nil {
+		return nil, err
+	}
+	if r.Version != REPLAY_VERSION {
+		return nil, fmt.Errorf("replay version %d is not supported", r.Version)
+	}
+	if len(r.Frames) == 0 {
+		return nil, fmt.Errorf("replay has no frames")
+	}
+	if _, ok := controlModeByName(r.Mode); !ok {
+		return nil, fmt.Errorf("replay has an unknown mode %q", r.Mode)
+	}
+	return r, nil
+}
+
+// ReadReplayFile loads a replay someone shared, e.g. with -ghost
+func ReadReplayFile(file string) (*Replay, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return ParseReplay(b)
+}
+
+func ghostFile(key string) string {
+	return "ghost-" + key + ".json"
+}
+
+// loadBestReplay returns nil when this mode and seed were never finished
+func loadBestReplay(key string) *Replay {
+	b, err := loadData(ghostFile(key))
+	if err != nil || b == nil {
+		return nil
+	}
+	r, err := ParseReplay(b)
+	if err != nil {
+		log.Println("Ghost is corrupted:", err)
+		return nil
+	}
+	return r
+}
+
+// saveIfBest keeps the replay when it beats the stored one
+func (r *Replay) saveIfBest() {
+	if best := loadBestReplay(r.Key()); best != nil && best.Score >= r.Score {
+		return
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		log.Println("Cannot encode replay:", err)
+		return
+	}
+	if err := saveData(ghostFile(r.Key()), b); err != nil {
+		log.Println("Cannot save replay:", err)
+	}
+}
+
+// Ghost plays a replay back next to the player
+type Ghost struct {
+	Replay *Replay
+	tick   int
+	frame  *ebiten.Image
+	proj   Projection
+}
+
+func NewGhost(r *Replay) *Ghost {
+	gh := &Ghost{Replay: r}
+	if frames, err := AtlasFrames("player"); err == nil && len(frames) > 0 {
+		gh.frame = Atlas.SubImage(*frames[0]).(*ebiten.Image)
+	}
+	return gh
+}
+
+func (gh *Ghost) current() [3]float32 {
+	return gh.Replay.Frames[min(gh.tick, len(gh.Replay.Frames)-1)]
+}
+
+func (gh *Ghost) Done() bool {
+	return gh.tick >= len(gh.Replay.Frames)
+}
+
+// Update advances one tick and projects onto the tower around the player like Sprite.Update
+func (gh *Ghost) Update(center float64) {
+	gh.tick++
+	gh.Project(center)
+}
+
+func (gh *Ghost) Project(center float64) {
+	if gh.frame == nil || gh.Done() {
+		gh.proj.Layer = Invisible
+		return
+	}
+	f := gh.current()
+	w, h := gh.frame.Bounds().Dx(), gh.frame.Bounds().Dy()
+	gh.proj = projectRect(float64(f[0]), float64(f[1]), float64(w), float64(h), center)
+}
+
+// Lead is how many seconds the player outlived the ghost, negative while the ghost is still going.
+// The score only counts ticks, so comparing it at the same tick would always come out even
+func (gh *Ghost) Lead() float64 {
+	return float64(gh.tick-len(gh.Replay.Frames)) / 60
+}
+
+func (gh *Ghost) Draw(world *ebiten.Image, layer LayerID) {
+	if gh.frame == nil || gh.proj.Layer != layer || gh.proj.Scale <= 0 {
+		return
+	}
+	dst := gh.proj.target(world)
+
+	if gh.proj.Behind && DitherShader != nil {
+		op := &ebiten.DrawRectShaderOptions{}
+		op.GeoM.Scale(gh.proj.Scale, 1)
+		op.GeoM.Translate(gh.proj.DrawPos[0], gh.proj.DrawPos[1])
+		op.Images[0] = gh.frame
+		op.Uniforms = map[string]interface{}{"Depth": float32(math.Max(gh.proj.Depth, 0.5))}
+		dst.DrawRectShader(gh.frame.Bounds().Dx(), gh.frame.Bounds().Dy(), DitherShader, op)
+		return
+	}
+
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Scale(gh.proj.Scale, 1)
+	op.GeoM.Translate(gh.proj.DrawPos[0], gh.proj.DrawPos[1])
+	op.ColorScale.ScaleAlpha(0.5)
+	dst.DrawImage(gh.frame, op)
+}
+
+// racingShared tells if the run follows the replay given with -ghost,
+// daily towers, online races and split screen keep their own tower
+func (g *Game) racingShared(players int) bool {
+	return g.sharedGhost != nil && g.daily == nil && g.net == nil && players == 1
+}
+
+// startRun seeds the tower and picks the ghost, a shared replay wins over the stored best
+// and brings its tower along. Only single player runs are recorded.
+func (g *Game) startRun() {
+	if g.racingShared(len(g.players)) {
+		g.seed, g.seeded = g.sharedGhost.Seed, true
+	}
+	if !g.seeded {
+		g.seed = rand.Int63()
+	}
+	SeedWorld(g.seed)
+	local := g.localPlayer().Object
+	Publish(g.events, RunStarted{
+		Seed:    g.seed,
+		Online:  g.net != nil,
+		Mode:    g.player.controls,
+		Players: len(g.players),
+		Start:   Vec2{local.Position.X, local.Position.Y},
+	})
+
+	g.recording = nil
+	g.ghost = nil
+	if len(g.players) > 1 {
+		return
+	}
+	g.recording = NewReplay(g)
+
+	switch {
+	case g.racingShared(len(g.players)):
+		g.ghost = NewGhost(g.sharedGhost)
+	default:
+		if best := loadBestReplay(g.recording.Key()); best != nil {
+			g.ghost = NewGhost(best)
+		}
+	}
+}
+
+func (g *Game) finishRun() {
+	if g.recording == nil || g.cheated {
+		return
+	}
+	g.recording.Score = g.score
+	g.recording.saveIfBest()
+	g.recording = nil
+}
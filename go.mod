@@ -2,20 +2,24 @@ module github.com/AndriiPets/1Bit
 
 go 1.22.3
 
+require (
+	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0
+	github.com/hajimehoshi/ebiten/v2 v2.8.0
+	github.com/solarlune/resolv v0.7.0
+	github.com/tanema/gween v0.0.0-20221212145351-621cc8a459d1
+	github.com/yohamta/ganim8/v2 v2.1.29
+	golang.org/x/image v0.20.0
+)
+
 require (
 	github.com/ebitengine/gomobile v0.0.0-20240911145611-4856209ac325 // indirect
 	github.com/ebitengine/hideconsole v1.0.0 // indirect
+	github.com/ebitengine/oto/v3 v3.3.0 // indirect
 	github.com/ebitengine/purego v0.8.0 // indirect
-	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
-	github.com/hajimehoshi/ebiten/v2 v2.8.0 // indirect
-	github.com/jakecoffman/cp/v2 v2.0.2 // indirect
 	github.com/jezek/xgb v1.1.1 // indirect
-	github.com/quasilyte/bitsweetfont v0.0.0-20240801221705-5e1264e4303a // indirect
-	github.com/solarlune/resolv v0.7.0 // indirect
-	github.com/tanema/gween v0.0.0-20221212145351-621cc8a459d1 // indirect
-	github.com/yohamta/ganim8/v2 v2.1.29 // indirect
+	github.com/jfreymuth/oggvorbis v1.0.5 // indirect
+	github.com/jfreymuth/vorbis v1.0.2 // indirect
 	golang.org/x/exp v0.0.0-20221126150942-6ab00d035af9 // indirect
-	golang.org/x/image v0.20.0 // indirect
 	golang.org/x/sync v0.8.0 // indirect
 	golang.org/x/sys v0.25.0 // indirect
 )
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// NewHUD lays out the text shown over the game, each panel decides from the game state if it is up
+func NewHUD(g *Game) *UIScreen {
+	restartKey := func() string { return Keys[ActionRestart].String() }
+	playing := func() bool { return !g.runOver() && !g.title }
+	versus := func() bool { return len(g.players) > 1 && g.party() == Versus }
+
+	score := NewPanel(AnchorTopLeft, image.Point{16, 16},
+		NewLabel(Msg("score"), false),
+		NewLabel(func() string { return Lang.FormatNumber(int(g.score)) }, false),
+	)
+	score.Visible = func() bool { return playing() && !versus() }
+
+	ghost := NewPanel(AnchorTopRight, image.Point{-16, 16},
+		NewLabel(func() string {
+			lead := g.ghost.Lead()
+			sign, secs := "+", math.Floor(lead)
+			if lead < 0 {
+				// round towards the ghost's end so it never reads -0 before the ghost is done
+				sign, secs = "-", math.Ceil(-lead)
+			}
+			return T("ghost_lead", "lead", sign+Lang.FormatNumber(int(secs)))
+		}, false),
+	)
+	ghost.Visible = func() bool { return playing() && g.ghost != nil && len(g.players) == 1 }
+
+	death := NewPanel(AnchorCenter, image.Point{0, -64},
+		NewLabel(func() string {
+			if w := g.winner(); w >= 0 {
+				return T("player_wins", "player", fmt.Sprint(w+1))
+			}
+			return T("you_died")
+		}, true),
+		NewLabel(func() string {
+			if w := g.winner(); w >= 0 {
+				return Tn("final_score", int(g.players[w].Score))
+			}
+			return Tn("final_score", int(g.score))
+		}, true),
+		NewLabel(func() string { return T("press_restart", "key", restartKey()) }, true),
+	)
+	death.Spacing = 16
+	death.Visible = func() bool { return g.runOver() && !g.title }
+
+	paused := NewPanel(AnchorCenter, image.Point{0, 32},
+		NewLabel(Msg("paused"), true),
+		NewLabel(Msg("press_settings", "key", "S"), false),
+	)
+	paused.Visible = func() bool { return g.paused }
+
+	var logo []Widget
+	for range 5 {
+		logo = append(logo, NewLabel(Static("+++HEXTOWER+++"), true))
+	}
+	title := NewPanel(AnchorCenter, image.Point{0, -64}, logo...)
+	title.Visible = func() bool { return g.title }
+
+	start := NewPanel(AnchorBottom, image.Point{0, -96},
+		NewLabel(func() string { return T("press_start", "key", restartKey()) }, false),
+		NewLabel(Msg("press_settings", "key", "S"), false),
+		NewLabel(Msg("press_daily", "key", "D"), false),
+		NewLabel(Msg("press_stats", "key", "T"), false),
+	)
+	start.Visible = func() bool { return g.title && g.net == nil && !g.showDaily }
+
+	errors := NewPanel(AnchorBottomLeft, image.Point{16, -16},
+		NewLabel(func() string { return Tn("assets_failed", len(g.loadErrors)) }, false),
+	)
+	errors.Visible = func() bool { return g.title && len(g.loadErrors) > 0 }
+
+	waiting := NewPanel(AnchorBottom, image.Point{0, -32},
+		NewLabel(func() string { return T("net_waiting", "room", g.net.config.Room) }, false),
+	)
+	waiting.Visible = func() bool { return g.net != nil && g.net.Waiting() }
+
+	netStatus := NewPanel(AnchorTop, image.Point{0, 16},
+		NewLabel(func() string { return T("net_ended", "reason", g.netStatus) }, false),
+	)
+	netStatus.Visible = func() bool { return g.title && g.net == nil && g.netStatus != "" }
+
+	dailyTag := NewPanel(AnchorTop, image.Point{0, 16},
+		NewLabel(func() string {
+			return T("daily_tag", "date", g.daily.Date, "profile", T("daily_"+g.daily.Profile.Name))
+		}, false),
+	)
+	dailyTag.Visible = func() bool { return playing() && g.daily != nil }
+
+	daily := NewPanel(AnchorBottom, image.Point{0, -16}, NewDailyResults(g))
+	daily.Visible = func() bool { return g.showDaily && g.dailyTable != nil && (g.title || g.runOver()) }
+
+	toast := NewPanel(AnchorBottomRight, image.Point{-16, -16},
+		NewLabel(Msg("achievement_unlocked"), false),
+		NewLabel(func() string { return T("ach_" + g.achievements.Toast()) }, true),
+		NewLabel(func() string { return T("ach_" + g.achievements.Toast() + "_desc") }, false),
+	)
+	toast.Visible = func() bool { return g.achievements.Toast() != "" }
+
+	panels := []*Panel{score, ghost, dailyTag, waiting, netStatus, death, paused, title, start, errors, daily, toast}
+	for _, p := range panels {
+		p.Background = color.Black
+	}
+	return NewUIScreen(panels...)
+}
+
+// NewViewHUD labels one player's part of a split screen
+func NewViewHUD(g *Game, index int, p *Player) *UIScreen {
+	name := NewPanel(AnchorTopLeft, image.Point{8, 8},
+		NewLabel(func() string {
+			label := T("player_label", "player", fmt.Sprint(index+1))
+			if g.net != nil && g.net.Local() == index {
+				label += " " + T("net_you")
+			}
+			if g.party() == Versus {
+				label += " " + Lang.FormatNumber(int(p.Score))
+			}
+			return label
+		}, false),
+	)
+	name.Background = color.Black
+	name.Visible = func() bool { return !g.title }
+
+	revive := NewPanel(AnchorCenter, image.Point{0, 0},
+		NewLabel(func() string {
+			if p.revive > 0 {
+				return T("reviving", "percent", fmt.Sprint(p.revive*100/REVIVE_TICKS))
+			}
+			return T("wait_revive")
+		}, false),
+	)
+	revive.Background = color.Black
+	revive.Visible = func() bool { return p.dead && !g.runOver() && g.party() == Coop }
+
+	return NewUIScreen(name, revive)
+}
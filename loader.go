@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"golang.org/x/image/font/basicfont"
+)
+
+type LoadStep struct {
+	Name     string
+	Run      func() error
+	Fallback func() // keeps the game running when Run fails, nil keeps whatever was there before
+}
+
+// Loader runs one step per tick so the loading screen can draw in between
+type Loader struct {
+	steps  []LoadStep
+	done   int
+	Errors []error
+}
+
+func NewLoader(steps ...LoadStep) *Loader {
+	return &Loader{steps: steps}
+}
+
+// Step runs the next step and returns true once everything is loaded
+func (l *Loader) Step() bool {
+	if l.done >= len(l.steps) {
+		return true
+	}
+
+	s := l.steps[l.done]
+	if err := s.Run(); err != nil {
+		err = fmt.Errorf("%s: %w", s.Name, err)
+		log.Println("Loading failed, using fallback:", err)
+		l.Errors = append(l.Errors, err)
+		if s.Fallback != nil {
+			s.Fallback()
+		}
+	}
+	l.done++
+
+	return l.done >= len(l.steps)
+}
+
+func (l *Loader) Progress() float64 {
+	return float64(l.done) / float64(len(l.steps))
+}
+
+// Draw uses the built in font, the game font may be what is loading
+func (l *Loader) Draw(screen *ebiten.Image) {
+	const barW, barH = 240, 12
+	x, y := float32(SCREEN_WIDTH-barW)/2, float32(HALF_HEIGHT)
+
+	label := "LOADING"
+	if l.done < len(l.steps) {
+		label += " " + l.steps[l.done].Name
+	}
+	text.Draw(screen, label, basicfont.Face7x13, int(x), int(y)-8, color.White)
+
+	vector.StrokeRect(screen, x, y, barW, barH, 1, color.White, false)
+	vector.DrawFilledRect(screen, x+2, y+2, float32(l.Progress())*(barW-4), barH-4, color.White, false)
+}
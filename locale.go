@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const langDir = "assets/lang"
+
+// Message is either a plain string or a set of plural forms keyed by category, e.g. {"one": "...", "other": "..."}
+type Message map[string]string
+
+func (m *Message) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*m = Message{"other": s}
+		return nil
+	}
+	var forms map[string]string
+	if err := json.Unmarshal(b, &forms); err != nil {
+		return err
+	}
+	if _, ok := forms["other"]; !ok {
+		return fmt.Errorf("plural forms need an \"other\" entry")
+	}
+	*m = forms
+	return nil
+}
+
+// Locale is one file in assets/lang, named by its language code
+type Locale struct {
+	Code     string             `json:"-"`
+	Name     string             `json:"name"`  // shown in the settings menu in its own language
+	Group    string             `json:"group"` // thousands separator
+	Plural   string             `json:"plural"`
+	Messages map[string]Message `json:"messages"`
+}
+
+// pluralRules map a count to a plural category, languages pick one by name
+var pluralRules = map[string]func(n int) string{
+	"one_other": func(n int) string {
+		if n == 1 {
+			return "one"
+		}
+		return "other"
+	},
+	"zero_one_other": func(n int) string {
+		if n == 0 || n == 1 {
+			return "one"
+		}
+		return "other"
+	},
+}
+
+// Lang is the active language, English stays loaded for messages a translation misses
+var (
+	Lang    = &Locale{Code: "en", Plural: "one_other"}
+	english = Lang
+)
+
+func loadLocale(code string) (*Locale, error) {
+	b, err := fs.ReadFile(Assets, path.Join(langDir, code+".json"))
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Locale{Code: code}
+	if err := json.Unmarshal(b, l); err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %w", code, err)
+	}
+	if _, ok := pluralRules[l.Plural]; !ok {
+		return nil, fmt.Errorf("%s: unknown plural rule %q", code, l.Plural)
+	}
+	return l, nil
+}
+
+// SetLanguage keeps the current language when the new one cannot be loaded,
+// english when none was set yet
+func SetLanguage(code string) error {
+	en, err := loadLocale("en")
+	if err != nil {
+		return err
+	}
+	english = en
+
+	if code == "en" {
+		Lang = en
+		return nil
+	}
+
+	l, err := loadLocale(code)
+	if err != nil {
+		if Lang.Messages == nil {
+			Lang = en
+		}
+		return err
+	}
+	Lang = l
+	return nil
+}
+
+// Languages lists the codes of every language file
+func Languages() []string {
+	entries, err := fs.ReadDir(Assets, langDir)
+	if err != nil {
+		return []string{"en"}
+	}
+
+	var codes []string
+	for _, e := range entries {
+		if path.Ext(e.Name()) == ".json" {
+			codes = append(codes, strings.TrimSuffix(e.Name(), ".json"))
+		}
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// LanguageName reads the name of a language without switching to it
+func LanguageName(code string) string {
+	if code == Lang.Code {
+		return Lang.Name
+	}
+	if l, err := loadLocale(code); err == nil {
+		return l.Name
+	}
+	return code
+}
+
+func (l *Locale) message(id string) (Message, bool) {
+	if m, ok := l.Messages[id]; ok {
+		return m, true
+	}
+	m, ok := english.Messages[id]
+	return m, ok
+}
+
+// FormatNumber groups the digits with the separator of the language
+func (l *Locale) FormatNumber(n int) string {
+	digits := strconv.Itoa(n)
+	sign := ""
+	if n < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	if l.Group == "" {
+		return sign + digits
+	}
+
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteString(l.Group)
+		}
+		b.WriteRune(d)
+	}
+	return sign + b.String()
+}
+
+// T looks up a message and fills its {name} placeholders from name, value pairs
+func T(id string, args ...string) string {
+	m, ok := Lang.message(id)
+	if !ok {
+		return id
+	}
+	return fill(m["other"], args)
+}
+
+// Tn picks the plural form for n and fills {n} with the formatted number
+func Tn(id string, n int, args ...string) string {
+	m, ok := Lang.message(id)
+	if !ok {
+		return id
+	}
+
+	form, ok := m[pluralRules[Lang.Plural](n)]
+	if !ok {
+		form = m["other"]
+	}
+	return fill(form, append(args, "n", Lang.FormatNumber(n)))
+}
+
+func fill(s string, args []string) string {
+	for i := 0; i+1 < len(args); i += 2 {
+		s = strings.ReplaceAll(s, "{"+args[i]+"}", args[i+1])
+	}
+	return s
+}
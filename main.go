@@ -2,36 +2,73 @@ package main
 
 import (
 	"bytes"
-	"embed"
+	"flag"
 	"fmt"
 	"image"
 	"image/color"
+	"io/fs"
 	"log"
 	"math"
+	"math/rand"
+	"os"
+	"runtime"
 
 	"github.com/golang/freetype/truetype"
 	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
-	"github.com/hajimehoshi/ebiten/v2/text"
 	"github.com/hajimehoshi/ebiten/v2/vector"
 	rv "github.com/solarlune/resolv"
 	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/gofont/goregular"
 )
 
 type Game struct {
 	space           *rv.Space
 	background      *TowerBackground
 	player          *Player
-	camera          Camera
+	camera          *Camera
 	world           *ebiten.Image
-	tower           *ebiten.Image
+	players         []*Player
+	views           []*View // one per player, background, player and camera above belong to the first
 	sprites         map[int]*Sprite
 	platformSpawner *PlatformSpawner
+	particles       *ParticleSystem
+	audio           *AudioManager
+	settings        *Settings
+	renderer        *Renderer
+	menu            *SettingsMenu
 	debug           bool
 	score           float64
 	font            font.Face
 	title           bool
+	paused          bool
+	watcher         *Watcher
+	dev             bool
+	loader          *Loader
+	loadErrors      []error
+	hud             *UIScreen
+	overlay         *DebugOverlay
+	console         *Console
+	cheated         bool // a console command ran this session
+	editor          *Editor
+	seed            int64
+	seeded          bool // the seed was chosen, otherwise every run rolls a new one
+	recording       *Replay
+	ghost           *Ghost
+	sharedGhost     *Replay // loaded with -ghost, raced instead of the best run
+	net             *NetRace
+	netConfig       *NetConfig // set with -relay, the race is joined once the world is loaded
+	netStatus       string     // why the last online race ended
+	daily           *DailyRun
+	dailyTable      *DailyTable
+	showDaily       bool // today's result is up
+	events          *Bus
+	level           int // score level reached by RaiseDiff
+	popups          *Popups
+	achievements    *Achievements
+	stats           *Stats
+	statsScreen     *StatsScreen
 }
 
 var GameSpeed = 2.0
@@ -47,42 +84,53 @@ const (
 	Invisible
 )
 
-//go:embed assets/*
-var assets embed.FS
-
-//go:embed assets/excel.ttf
-var exelFont []byte
-
-func readImage(file string) image.Image {
-	b, err := assets.ReadFile(file)
+func readImage(file string) (image.Image, error) {
+	b, err := fs.ReadFile(Assets, file)
 	if err != nil {
-		panic(fmt.Sprintf("Cannot find a file %s", file))
+		return nil, fmt.Errorf("cannot find a file %s: %w", file, err)
 	}
 	return bytes2Image(&b)
 }
 
-func bytes2Image(raw *[]byte) image.Image {
+func bytes2Image(raw *[]byte) (image.Image, error) {
 	img, format, err := image.Decode(bytes.NewReader(*raw))
 	if err != nil {
-		log.Fatal("Byte2Image Failed:", format, err)
+		return nil, fmt.Errorf("byte2Image failed: %s %w", format, err)
 	}
 
-	return img
+	return img, nil
 }
 
 var (
 	startPos   = Vec2{400, WORLD_HEIGTH - 32}
 	Atlas      *ebiten.Image
-	AtlasW     = 384
-	AtlasH     = 512
+	AtlasW     int
+	AtlasH     int
+	Animations *AtlasMeta
 	Difficulty = 0
 	Font       font.Face
 	FontBig    font.Face
 )
 
-func init() {
-	Atlas = ebiten.NewImageFromImage(readImage("assets/tile_atlas.png"))
-	fontData, _ := truetype.Parse(exelFont)
+func loadShaders() error {
+	shader, err := ebiten.NewShader([]byte(ditherSrc))
+	if err != nil {
+		return err
+	}
+	DitherShader = shader
+	return nil
+}
+
+func loadFonts() error {
+	exelFont, err := fs.ReadFile(Assets, "assets/excel.ttf")
+	if err != nil {
+		return err
+	}
+
+	fontData, err := truetype.Parse(exelFont)
+	if err != nil {
+		return err
+	}
 	opts := &truetype.Options{
 		Size:    18,
 		DPI:     72,
@@ -93,29 +141,75 @@ func init() {
 		DPI:     72,
 		Hinting: font.HintingFull,
 	}
-	Font = truetype.NewFace(fontData, opts)
-	FontBig = truetype.NewFace(fontData, optsBig)
+	//translations can use glyphs the pixel font does not have
+	fallback, err := truetype.Parse(goregular.TTF)
+	if err != nil {
+		return err
+	}
+	has := func(r rune) bool { return fontData.Index(r) != 0 }
+
+	Font = &fallbackFace{Face: truetype.NewFace(fontData, opts), fallback: truetype.NewFace(fallback, opts), has: has}
+	FontBig = &fallbackFace{Face: truetype.NewFace(fontData, optsBig), fallback: truetype.NewFace(fallback, optsBig), has: has}
+	return nil
 }
 
+func useBasicFont() {
+	Font = basicfont.Face7x13
+	FontBig = basicfont.Face7x13
+}
+
+// NewGame only opens the loading screen, assets are loaded step by step while it is shown
 func NewGame() *Game {
 	g := &Game{}
+	g.settings = LoadSettings()
+	g.settings.applyDisplay()
+	g.renderer = NewRenderer()
+
+	g.loader = NewLoader(
+		LoadStep{Name: "fonts", Run: loadFonts, Fallback: useBasicFont},
+		LoadStep{Name: "language", Run: func() error { return SetLanguage(g.settings.Language) }},
+		LoadStep{Name: "atlas", Run: loadAtlasAssets, Fallback: usePlaceholderAtlas},
+		LoadStep{Name: "shaders", Run: loadShaders},
+		LoadStep{Name: "tuning", Run: loadTuning},
+		LoadStep{Name: "platforms", Run: loadPlatformDefs, Fallback: usePlatformDefaults},
+		LoadStep{Name: "chunks", Run: loadChunks},
+		LoadStep{Name: "achievements", Run: loadAchievementDefs},
+		LoadStep{Name: "sounds", Run: func() error {
+			g.audio = NewAudioManager()
+			return nil
+		}},
+		LoadStep{Name: "world", Run: func() error {
+			g.setup()
+			return nil
+		}},
+	)
+
+	return g
+}
+
+func (g *Game) setup() {
 	g.space = rv.NewSpace(WORLD_WIDTH, WORLD_HEIGTH+HALF_HEIGHT, 16, 16)
+	g.events = NewBus()
 	g.sprites = make(map[int]*Sprite)
 	g.platformSpawner = NewPlatformSpawner(g, 100)
+	g.particles = NewParticleSystem(256)
+	g.audio.PlayMusic()
 	g.score = 0
 
 	g.title = true
 
-	player := NewPlayer(g, startPos)
-	g.player = player
-
 	g.world = ebiten.NewImage(WORLD_WIDTH, WORLD_HEIGTH+HALF_HEIGHT) //circumference of the tower
-	g.tower = ebiten.NewImage(TOWER_WIDTH, SCREEN_HEIGHT+HALF_HEIGHT)
-	g.camera = Camera{
-		ViewPort:   Vec2{SCREEN_WIDTH, SCREEN_HEIGHT},
-		ZoomFactor: 48,
-		Position:   startPos,
-	}
+	g.achievements = LoadAchievements()
+	g.stats = LoadStats()
+	g.popups = NewPopups()
+
+	g.audio.Listen(g.events)
+	g.particles.Listen(g.events)
+	g.achievements.Listen(g.events)
+	g.stats.Listen(g.events)
+	g.listenCameras(g.events)
+	g.popups.Listen(g.events)
+	g.setupParty()
 
 	//platforms
 	//g.platformSpawner.Spawn(Vec2{400, 400}, "platform")
@@ -126,10 +220,18 @@ func NewGame() *Game {
 	//)
 
 	g.fillPockets(WORLD_HEIGTH)
-	g.background = NewBackground(g.tower)
-
-	return g
-
+	g.hud = NewHUD(g)
+	g.overlay = NewDebugOverlay(g)
+	g.console = NewConsole()
+	g.editor = NewEditor(g)
+	g.settings.Apply(g)
+
+	if g.dev {
+		g.watcher = NewWatcher(Assets, devFiles()...)
+	}
+	if g.netConfig != nil {
+		g.joinRace(*g.netConfig)
+	}
 }
 
 // fill sides with objects for smoth rotation
@@ -159,178 +261,343 @@ func (g *Game) fillPockets(yOffset float64) {
 }
 
 func (g *Game) Restart() {
-	GameSpeed = 2.0
+	if g.daily != nil && g.daily.started {
+		g.leaveDaily()
+	}
+	GameSpeed = Tuning.StartSpeed
+	Difficulty = 0
 	g.score = 0.0
-	g.player.dead = false
+	g.level = 0
 	g.platformSpawner.Sweep()
-	g.player.Object.Position.Y = startPos[1]
+	g.particles.Clear()
+	g.popups.Clear()
+	g.setupParty()
+	g.startRun()
 }
 
+// RaiseDiff goes up a level when the score passes the next multiple of DifficultyEvery,
+// near miss bonuses can jump over the exact number
 func (g *Game) RaiseDiff() {
-	if int(g.score)%20 == 0 && int(g.score) > 0 {
-		GameSpeed += 0.3
+	if level := int(g.score) / Tuning.DifficultyEvery; level > g.level {
+		g.level = level
+		GameSpeed += Tuning.SpeedStep
 		Difficulty++
+		Publish(g.events, DifficultyRaised{Level: Difficulty})
 		g.score++
 		return
 	}
 }
-func (g *Game) Update() error {
+
+// cheat marks the session so it no longer counts for achievements, stats and replays
+func (g *Game) cheat() {
+	if !g.cheated {
+		g.cheated = true
+		Publish(g.events, Cheated{})
+	}
+}
+
+// updateToggles handles the debug overlay and fullscreen keys
+func (g *Game) updateToggles() {
 	if inpututil.IsKeyJustPressed(ebiten.KeyF1) {
 		g.debug = !g.debug
 	}
 
 	if inpututil.IsKeyJustPressed(ebiten.KeyF2) {
-		ebiten.SetFullscreen(!ebiten.IsFullscreen())
+		g.settings.Fullscreen = !ebiten.IsFullscreen()
+		ebiten.SetFullscreen(g.settings.Fullscreen)
+		g.settings.Save()
 	}
+}
 
-	if inpututil.IsKeyJustPressed(ebiten.KeyR) {
-		if g.player.dead || g.title {
-			g.Restart()
-			g.title = false
+func (g *Game) updateMute() {
+	if inpututil.IsKeyJustPressed(Keys[ActionMute]) {
+		g.audio.ToggleMute()
+		g.settings.Muted = g.audio.Muted
+		g.settings.Save()
+	}
+}
+
+func (g *Game) Update() error {
+	if g.loader != nil {
+		if g.loader.Step() {
+			g.loadErrors = g.loader.Errors
+			g.loader = nil
 		}
+		return nil
 	}
 
-	if g.player.Speed.X != 0 && !g.player.stuck && !g.player.dead {
+	// the game data of an online race stays as both sides agreed on
+	if g.watcher != nil && g.net == nil {
+		g.hotReload()
+	}
+
+	// a race cannot wait for anyone, the console, the editor and the menus stay closed during it
+	if g.net != nil {
+		g.updateToggles()
+		g.updateMute()
+		g.net.Update(g)
+		g.audio.Update()
+		return nil
+	}
 
-		if !g.player.FacingRight {
-			g.background.Flip(true)
-			g.player.Sprite.Animation.Sprite().SetFlipH(true)
-		} else {
-			g.background.Flip(false)
-			g.player.Sprite.Animation.Sprite().SetFlipH(false)
+	g.console.Update(g)
+	if g.console.Open {
+		g.audio.Update()
+		return nil
+	}
+
+	// the editor saves into the folder dev mode reads the assets from
+	if inpututil.IsKeyJustPressed(ebiten.KeyF3) && g.menu == nil && g.dev {
+		g.editor.Toggle()
+	}
+	if g.editor.Active && !g.editor.playing {
+		g.editor.Update()
+		g.audio.Update()
+		return nil
+	}
+
+	g.updateToggles()
+
+	if g.menu != nil {
+		if !g.menu.Update(g) {
+			g.menu = nil
 		}
+		g.audio.Update()
+		return nil
+	}
 
-		g.background.Update()
+	if g.statsScreen != nil {
+		if !g.statsScreen.Update(g) {
+			g.statsScreen = nil
+		}
+		g.audio.Update()
+		return nil
 	}
 
-	if !g.player.dead && !g.title {
+	if inpututil.IsKeyJustPressed(ebiten.KeyT) && g.title {
+		g.statsScreen = NewStatsScreen(g.stats)
+		g.audio.Play(SoundMenu)
+	}
 
-		g.platformSpawner.Update()
+	if inpututil.IsKeyJustPressed(ebiten.KeyS) && (g.title || g.paused) {
+		g.menu = NewSettingsMenu(g.settings)
+		g.audio.Play(SoundMenu)
+	}
 
-		g.score += GameSpeed / 60
-		//fmt.Println(int(g.score))
+	g.updateMute()
 
-		g.RaiseDiff()
+	if g.showDaily && g.title && inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		g.showDaily = false
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyD) && g.title {
+		g.startDaily()
+		g.audio.Play(SoundMenu)
 	}
 
-	g.player.PlayerUpdate()
-	if g.player.dead {
-		GameSpeed = 0.0
+	if inpututil.IsKeyJustPressed(Keys[ActionRestart]) {
+		if g.daily != nil && g.runOver() {
+			// the attempt is spent, back to the title with the result still up
+			g.Restart()
+			g.title = true
+		} else if (g.runOver() || g.title) && g.daily == nil {
+			g.showDaily = false
+			g.Restart()
+			g.title = false
+			g.audio.Play(SoundMenu)
+		}
 	}
 
-	for _, s := range g.sprites {
-		s.Update(g)
+	if inpututil.IsKeyJustPressed(Keys[ActionPause]) && !g.title && !g.runOver() {
+		g.paused = !g.paused
+		g.audio.SetPaused(g.paused)
+		g.audio.Play(SoundMenu)
+	}
+
+	g.audio.Update()
+	if g.paused {
+		return nil
 	}
 
-	playerPos := Vec2{g.player.Object.Position.X, g.player.Object.Position.Y}
-	g.camera.Update(playerPos)
+	g.step()
 	return nil
 }
 
-func (g *Game) Draw(screen *ebiten.Image) {
-	screen.Clear()
-	g.world.Clear()
-	g.tower.Clear()
+// step is one tick of the simulation, online races only run it once every input for the tick is known
+func (g *Game) step() {
+	for i, p := range g.players {
+		if p.Speed.X != 0 && !p.stuck && !p.dead {
+			g.views[i].Background.Flip(!p.FacingRight)
+			p.Sprite.Animation.Sprite().SetFlipH(!p.FacingRight)
+			g.views[i].Background.Update()
+		}
+	}
 
-	//ebitenutil.DebugPrint(screen, strconv.Itoa(g.background.frame))
-	for _, s := range g.sprites {
-		if s.Object != nil {
-			if s.Layer == BehindTower {
-				//obj := s.Object
-				//fmt.Println(s.Color, s.DrawPos)
-				//vector.DrawFilledRect(g.world, float32(s.DrawPos[0]), float32(s.DrawPos[1]), float32(obj.Size.X), float32(obj.Size.Y), s.Color, false)
-				s.Draw(g.world)
+	if !g.runOver() && !g.title {
 
+		g.platformSpawner.Update()
+
+		milestone := int(g.score) / SCORE_MILESTONE
+		g.score += GameSpeed / 60
+		//fmt.Println(int(g.score))
+
+		for _, p := range g.players {
+			if p.dead {
+				continue
+			}
+			p.Score += GameSpeed / 60
+			if rand.Intn(3) == 0 {
+				obj := p.Object
+				g.particles.Emit(g.particles.Trail, Vec2{obj.Position.X + obj.Size.X/2 - 2, obj.Bottom()}, 1)
 			}
 		}
+
+		g.RaiseDiff()
+		if m := int(g.score) / SCORE_MILESTONE; m > milestone {
+			Publish(g.events, ScoreMilestone{Score: m * SCORE_MILESTONE})
+		}
+
+		if g.recording != nil {
+			g.recording.Record(g)
+		}
+		if g.ghost != nil {
+			g.ghost.Update(g.player.Object.Position.X)
+		}
 	}
 
-	g.background.Draw(g.world, g.player.Object.Position.X, g.player.Object.Position.Y)
+	wasOver := g.runOver()
+	for i, p := range g.players {
+		cam := g.views[i].Camera
 
-	for _, s := range g.sprites {
-		if s.Object != nil {
-			if s.Layer == BeforeTower {
-				//obj := s.Object
-				//fmt.Println(s.Color, s.DrawPos)
-				//vector.DrawFilledRect(g.world, float32(s.DrawPos[0]), float32(s.DrawPos[1]), float32(obj.Size.X), float32(obj.Size.Y), s.Color, false)
-				if !g.title {
-					s.Draw(g.world)
-				}
+		p.PlayerUpdate()
+		g.checkNearMisses(p)
 
-			}
+		if p.Jumped {
+			g.audio.Play(SoundJump)
 		}
-	}
 
-	//worldX, worldY := g.camera.ScreenToWorld(g.player.Object.CellPosition())
-	//ebitenutil.DebugPrint(
-	//	screen,
-	//	strconv.FormatFloat(ebiten.ActualFPS(), 'f', 1, 64),
-	//)
+		if p.Impact > 0 {
+			cam.Shake(p.Impact / Tuning.JumpSpeed * 0.5)
+		}
+	}
+	g.revive()
 
-	//ebitenutil.DebugPrintAt(
-	//	screen,
-	//	fmt.Sprintf("%s\nCursor World Pos: %.2f, %.2f", g.camera.String(), worldX, worldY),
-	//	0, SCREEN_HEIGHT-32,
-	//)
+	if g.runOver() {
+		if !wasOver {
+			g.finishRun()
+			if g.daily != nil {
+				g.finishDaily()
+			}
+			Publish(g.events, RunEnded{Score: int(g.score), Difficulty: Difficulty})
+		}
+		GameSpeed = 0.0
+	}
+	Publish(g.events, Ticked{Playing: !g.title && !g.runOver(), Local: g.localPlayer().state()})
+	g.popups.Update()
+	scrollTower()
 
-	if g.debug {
-		g.DebugDraw(g.world)
+	for _, s := range g.sprites {
+		s.Update(g)
 	}
+	g.particles.Update(g.player.Object.Position.X)
 
-	g.camera.Render(g.world, screen)
+	for _, v := range g.views {
+		pos := v.Player.Object.Position
+		v.Camera.Update(Vec2{pos.X, pos.Y}, GameSpeed)
+	}
+}
 
-	//UI
+func (g *Game) Draw(screen *ebiten.Image) {
+	g.renderer.canvas.Clear()
+	if g.loader != nil {
+		g.loader.Draw(g.renderer.canvas)
+	} else {
+		g.drawCanvas(g.renderer.canvas)
+		g.console.Draw(g.renderer.canvas)
+	}
+	g.renderer.Present(screen)
+}
 
-	if !g.player.dead && !g.title {
-		g.DrawText(screen, 16, 16, Font, "Score: ", fmt.Sprintf("%d", int(g.score)))
+func (g *Game) drawCanvas(screen *ebiten.Image) {
+	if g.menu != nil {
+		g.menu.Draw(screen)
+		return
+	}
+	if g.statsScreen != nil {
+		g.statsScreen.Draw(screen)
+		return
 	}
 
-	if g.player.dead {
-		g.DrawText(screen,
-			170,
-			HALF_HEIGHT-128,
-			FontBig,
-			"+++YOU DIED!+++", "", fmt.Sprintf("++Final Score: %d++", int(g.score)), "", "press R to restart")
+	if g.editor.Active && !g.editor.playing {
+		g.editor.DrawWorld(g.world)
+		g.camera.Render(g.world, screen)
+		g.editor.Draw(screen)
+		return
 	}
 
-	if g.title {
-		g.DrawText(
-			screen,
-			170,
-			HALF_HEIGHT-128,
-			FontBig,
-			"+++HEXTOWER+++", "+++HEXTOWER+++", "+++HEXTOWER+++", "+++HEXTOWER+++", "+++HEXTOWER+++",
-		)
+	for _, v := range g.views {
+		g.drawView(v, screen)
 	}
 
+	g.hud.Draw(screen)
+	if g.debug {
+		g.overlay.Draw(screen)
+	}
 }
 
+// the screen matches the window in device pixels, the renderer scales the canvas into it
 func (g *Game) Layout(outsideW, outsideH int) (int, int) {
-	return SCREEN_WIDTH, SCREEN_HEIGHT
+	s := ebiten.Monitor().DeviceScaleFactor()
+	return int(float64(outsideW) * s), int(float64(outsideH) * s)
 }
 
 func main() {
-	ebiten.SetVsyncEnabled(true)
-	ebiten.SetWindowSize(SCREEN_WIDTH, SCREEN_HEIGHT)
+	dev := flag.Bool("dev", false, "read assets from disk and reload them when they change")
+	dir := flag.String("assets", ".", "folder containing the assets folder, used in dev mode")
+	modsDir := flag.String("mods", "mods", "folder with asset mods, each subfolder is one mod")
+	ghostFile := flag.String("ghost", "", "replay file to race against instead of your best run")
+	relay := flag.String("relay", "", "host:port of a relay to race someone online")
+	room := flag.String("room", "public", "relay room, the first two players in a room race each other")
+	netLatency := flag.Duration("net-latency", 0, "simulated one way latency for testing online races")
+	netJitter := flag.Duration("net-jitter", 0, "simulated random extra latency up to this duration")
+	netLoss := flag.Float64("net-loss", 0, "simulated share of lost packets, 0 to 1")
+	flag.Parse()
+
+	if *dev {
+		Assets = os.DirFS(*dir)
+		assetsRoot = *dir
+	}
+
+	//the browser build has no file system to load mods from
+	if runtime.GOOS != "js" {
+		overlay, err := NewOverlay(Assets, *modsDir)
+		if err != nil {
+			log.Println("Cannot read mods:", err)
+		} else if len(overlay.mods) > 0 {
+			overlay.Validate()
+			Assets = overlay
+		}
+	}
+
 	ebiten.SetWindowTitle("HEXTOWER")
+	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
+	ebiten.SetWindowSizeLimits(SCREEN_WIDTH/2, SCREEN_HEIGHT/2, -1, -1)
 	game := NewGame()
+	game.dev = *dev
+	if *relay != "" {
+		game.netConfig = &NetConfig{Relay: *relay, Room: *room, Latency: *netLatency, Jitter: *netJitter, Loss: *netLoss}
+	}
+	if *ghostFile != "" {
+		r, err := ReadReplayFile(*ghostFile)
+		if err != nil {
+			log.Println("Cannot load ghost:", err)
+		}
+		game.sharedGhost = r
+	}
 	if err := ebiten.RunGame(game); err != nil {
 		panic(err)
 	}
 }
 
-func (g *Game) DrawText(screen *ebiten.Image, x, y int, fnt font.Face, textLines ...string) {
-	rectHeight := 38
-	for _, txt := range textLines {
-		w := float64(font.MeasureString(fnt, txt).Round())
-		ebitenutil.DrawRect(screen, float64(x), float64(y-rectHeight+10), w, float64(rectHeight), color.RGBA{0, 0, 0, 255})
-
-		text.Draw(screen, txt, fnt, x+1, y+1, color.RGBA{255, 255, 255, 255})
-		text.Draw(screen, txt, fnt, x, y, color.RGBA{0, 0, 0, 0})
-		y += rectHeight
-	}
-}
-
 func (g *Game) DebugDraw(screen *ebiten.Image) {
 
 	space := g.space
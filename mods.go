@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/freetype/truetype"
+)
+
+type modLayer struct {
+	name     string
+	fsys     fs.FS
+	disabled map[string]bool
+}
+
+// Overlay looks for every asset in the mods first, then in the base assets.
+// Each folder in the mods directory is one mod with the same layout as the game, e.g. mods/dark/assets/tile_atlas.png
+type Overlay struct {
+	base fs.FS
+	mods []*modLayer // highest priority first
+}
+
+// NewOverlay finds the mods in dir, they are applied in name order so later mods win
+func NewOverlay(base fs.FS, dir string) (*Overlay, error) {
+	o := &Overlay{base: base}
+
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return o, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].IsDir() {
+			o.mods = append(o.mods, &modLayer{
+				name:     entries[i].Name(),
+				fsys:     os.DirFS(filepath.Join(dir, entries[i].Name())),
+				disabled: make(map[string]bool),
+			})
+		}
+	}
+	return o, nil
+}
+
+func (o *Overlay) Open(name string) (fs.File, error) {
+	for _, m := range o.mods {
+		if m.disabled[name] {
+			continue
+		}
+		f, err := m.fsys.Open(name)
+		if err == nil {
+			return f, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("mod %s: %w", m.name, err)
+		}
+	}
+	return o.base.Open(name)
+}
+
+// files lists what every mod overrides
+func (m *modLayer) files() []string {
+	var files []string
+	fs.WalkDir(m.fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			files = append(files, p)
+		}
+		return nil
+	})
+	return files
+}
+
+// provider returns the mod a file is read from, or nil for the base assets
+func (o *Overlay) provider(name string) *modLayer {
+	for _, m := range o.mods {
+		if m.disabled[name] {
+			continue
+		}
+		if _, err := fs.Stat(m.fsys, name); err == nil {
+			return m
+		}
+	}
+	return nil
+}
+
+func (o *Overlay) disable(m *modLayer, name string, err error) {
+	log.Printf("Mod %s: %s is ignored, %v", m.name, name, err)
+	m.disabled[name] = true
+}
+
+// Validate reports mods overriding the same file and drops files the game cannot use,
+// it has to run before the assets are loaded through the overlay
+func (o *Overlay) Validate() {
+	owners := make(map[string][]string)
+	for i := len(o.mods) - 1; i >= 0; i-- {
+		for _, f := range o.mods[i].files() {
+			owners[f] = append(owners[f], o.mods[i].name)
+		}
+	}
+	names := make([]string, 0, len(owners))
+	for f := range owners {
+		names = append(names, f)
+	}
+	sort.Strings(names)
+	for _, f := range names {
+		if mods := owners[f]; len(mods) > 1 {
+			log.Printf("Mod conflict: %s is replaced by %s, %s wins", f, strings.Join(mods, ", "), mods[len(mods)-1])
+		}
+	}
+
+	//go through the files in the order the game needs them, the atlas image is checked against the winning metadata
+	o.validateFile("assets/atlas.json", func(b []byte) error {
+		_, err := LoadAtlasMeta(b)
+		return err
+	})
+
+	o.validateAtlas()
+	meta, err := o.readMeta()
+	if err != nil {
+		log.Println("Atlas metadata cannot be read for mod checks:", err)
+		return
+	}
+
+	o.validateFile("assets/excel.ttf", func(b []byte) error {
+		_, err := truetype.Parse(b)
+		return err
+	})
+
+	//any other image has to keep the size of the one it replaces
+	for _, f := range names {
+		if path.Ext(f) != ".png" || f == meta.Image {
+			continue
+		}
+		base, err := fs.ReadFile(o.base, f)
+		if err != nil {
+			continue
+		}
+		want, _, err := image.DecodeConfig(bytes.NewReader(base))
+		if err != nil {
+			continue
+		}
+		o.validateFile(f, func(b []byte) error {
+			cfg, _, err := image.DecodeConfig(bytes.NewReader(b))
+			if err != nil {
+				return err
+			}
+			if cfg.Width != want.Width || cfg.Height != want.Height {
+				return fmt.Errorf("image is %dx%d but replaces one of %dx%d", cfg.Width, cfg.Height, want.Width, want.Height)
+			}
+			return nil
+		})
+	}
+}
+
+// validateFile checks the file from each mod in turn until one passes or the base is reached
+func (o *Overlay) validateFile(name string, check func([]byte) error) {
+	for m := o.provider(name); m != nil; m = o.provider(name) {
+		b, err := fs.ReadFile(m.fsys, name)
+		if err == nil {
+			err = check(b)
+		}
+		if err == nil {
+			return
+		}
+		o.disable(m, name, err)
+	}
+}
+
+// validateAtlas checks the winning metadata against the atlas image whoever provides either.
+// Image mods that do not fit are dropped, when not even the base image fits the metadata mod goes
+func (o *Overlay) validateAtlas() {
+	for {
+		meta, err := o.readMeta()
+		if err != nil {
+			log.Println("Atlas metadata cannot be read for mod checks:", err)
+			return
+		}
+
+		fits := func(fsys fs.FS) error {
+			b, err := fs.ReadFile(fsys, meta.Image)
+			if err != nil {
+				return err
+			}
+			cfg, _, err := image.DecodeConfig(bytes.NewReader(b))
+			if err != nil {
+				return err
+			}
+			need := meta.extent()
+			if cfg.Width < need.X || cfg.Height < need.Y {
+				return fmt.Errorf("image is %dx%d but the atlas metadata needs at least %dx%d", cfg.Width, cfg.Height, need.X, need.Y)
+			}
+			return meta.check(cfg.Width, cfg.Height)
+		}
+
+		//the first image that fits wins, the mods above it are dropped
+		var misfits []*modLayer
+		var errs []error
+		fitted := false
+		for _, m := range o.mods {
+			if _, err := fs.Stat(m.fsys, meta.Image); m.disabled[meta.Image] || err != nil {
+				continue
+			}
+			if err = fits(m.fsys); err == nil {
+				fitted = true
+				break
+			}
+			misfits, errs = append(misfits, m), append(errs, err)
+		}
+		if !fitted {
+			err = fits(o.base)
+			fitted = err == nil
+		}
+
+		if fitted {
+			for i, m := range misfits {
+				o.disable(m, meta.Image, errs[i])
+			}
+			return
+		}
+		m := o.provider("assets/atlas.json")
+		if m == nil {
+			log.Println("Atlas metadata does not fit any image:", err)
+			return
+		}
+		o.disable(m, "assets/atlas.json", err)
+	}
+}
+
+func (o *Overlay) readMeta() (*AtlasMeta, error) {
+	b, err := fs.ReadFile(o, "assets/atlas.json")
+	if err != nil {
+		return nil, err
+	}
+	return LoadAtlasMeta(b)
+}
+
+// extent is the size the atlas image must have to hold every animation
+func (meta *AtlasMeta) extent() image.Point {
+	var p image.Point
+	for _, a := range meta.Animations {
+		cols, rows := 0, 0
+		for i, f := range a.Frames {
+			n := 0
+			switch v := f.(type) {
+			case int:
+				n = v
+			case string:
+				for _, part := range strings.Split(v, "-") {
+					k, _ := strconv.Atoi(strings.TrimSpace(part))
+					n = max(n, k)
+				}
+			}
+			if i%2 == 0 {
+				cols = max(cols, n)
+			} else {
+				rows = max(rows, n)
+			}
+		}
+		p.X = max(p.X, a.Origin[0]+cols*a.Frame[0])
+		p.Y = max(p.Y, a.Origin[1]+rows*a.Frame[1])
+	}
+	return p
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	rv "github.com/solarlune/resolv"
+)
+
+// nearMisses follows the platforms close to one player until they pass or get touched
+type nearMisses struct {
+	close []closePlatform
+	combo int
+	quiet int // ticks since the last near miss
+}
+
+type closePlatform struct {
+	platform *Platform
+	from     int // side of the player it came in from, 0 when it came in level with the player
+	touched  bool
+	seen     bool
+}
+
+// gap is how far apart two boxes are, 0 or less when they touch
+func gap(a, b *rv.Object) float64 {
+	dx := math.Max(b.Position.X-a.Right(), a.Position.X-b.Right())
+	dy := math.Max(b.Position.Y-a.Bottom(), a.Position.Y-b.Bottom())
+	return math.Max(dx, dy)
+}
+
+// side is -1 when b is wholly above a, 1 when wholly below and 0 when they overlap vertically
+func side(a, b *rv.Object) int {
+	switch {
+	case b.Bottom() <= a.Position.Y:
+		return -1
+	case b.Position.Y >= a.Bottom():
+		return 1
+	}
+	return 0
+}
+
+// checkNearMisses asks the space for platforms around the player and scores the ones that
+// crossed the player vertically inside the margin without touching, coming in on one side
+// of the player's box and leaving on the other
+func (g *Game) checkNearMisses(p *Player) {
+	n := &p.near
+	n.quiet++
+	if float64(n.quiet) > Tuning.ComboWindow*60 {
+		n.combo = 0
+	}
+
+	if p.dead || g.title || g.runOver() {
+		n.close = n.close[:0]
+		return
+	}
+
+	m := Tuning.NearMissMargin
+	obj := p.Object
+	for _, o := range g.space.CheckWorld(obj.Position.X-m, obj.Position.Y-m, obj.Size.X+2*m, obj.Size.Y+2*m, "platform") {
+		platform, ok := o.Data.(*Platform)
+		d := gap(obj, o)
+		if !ok || d > m {
+			continue
+		}
+
+		i := -1
+		for j, c := range n.close {
+			if c.platform == platform {
+				i = j
+			}
+		}
+		if i < 0 {
+			n.close = append(n.close, closePlatform{platform: platform, from: side(obj, o)})
+			i = len(n.close) - 1
+		}
+		n.close[i].seen = true
+		n.close[i].touched = n.close[i].touched || d <= 0 || p.OnGround == o
+	}
+
+	kept := n.close[:0]
+	for _, c := range n.close {
+		switch {
+		case c.seen:
+			c.seen = false
+			kept = append(kept, c)
+		case !c.touched && c.platform.used && c.from != 0 && side(obj, c.platform.Object) == -c.from:
+			g.awardNearMiss(p, c.platform)
+		}
+	}
+	n.close = kept
+}
+
+func (g *Game) awardNearMiss(p *Player, platform *Platform) {
+	n := &p.near
+	n.combo = min(n.combo+1, max(1, Tuning.ComboMax))
+	n.quiet = 0
+
+	bonus := Tuning.NearMissBonus * float64(n.combo)
+	p.Score += bonus
+	g.score += bonus
+	Publish(g.events, NearMiss{Player: p.Index, Local: p.Local, Pos: p.center(), Combo: n.combo, Bonus: bonus})
+}
+
+const POPUP_TICKS = 45
+
+// Popup is a bit of text floating up from a point in the world
+type Popup struct {
+	Text string
+	Pos  Vec2
+	age  int
+}
+
+type Popups struct {
+	items []*Popup
+}
+
+func NewPopups() *Popups {
+	return &Popups{}
+}
+
+func (ps *Popups) Listen(bus *Bus) {
+	Subscribe(bus, func(e NearMiss) {
+		text := fmt.Sprintf("+%d", int(e.Bonus))
+		if e.Combo > 1 {
+			text += fmt.Sprintf(" x%d", e.Combo)
+		}
+		ps.items = append(ps.items, &Popup{Text: text, Pos: Vec2{e.Pos[0] - 8, e.Pos[1] - 16}})
+	})
+}
+
+func (ps *Popups) Clear() {
+	ps.items = ps.items[:0]
+}
+
+func (ps *Popups) Update() {
+	kept := ps.items[:0]
+	for _, p := range ps.items {
+		p.age++
+		p.Pos[1] -= 0.5
+		if p.age < POPUP_TICKS {
+			kept = append(kept, p)
+		}
+	}
+	ps.items = kept
+}
+
+// Draw projects the popups for a view centered on x, the ones behind the tower are skipped
+func (ps *Popups) Draw(world *ebiten.Image, center float64) {
+	for _, p := range ps.items {
+		pr := projectRect(p.Pos[0], p.Pos[1], 1, 1, center)
+		if pr.Behind {
+			continue
+		}
+		a := uint8(255 * (1 - float64(p.age)/POPUP_TICKS))
+		at := image.Point{int(pr.DrawPos[0]), int(pr.DrawPos[1])}
+		drawString(world, p.Text, Font, at, color.NRGBA{255, 255, 255, a})
+	}
+}
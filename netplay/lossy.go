@@ -0,0 +1,52 @@
+package netplay
+
+import (
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// Lossy wraps a connection to test on loopback, outgoing packets are delayed and dropped
+type Lossy struct {
+	net.PacketConn
+	Latency time.Duration
+	Jitter  time.Duration
+	Loss    float64 // share of packets dropped, 0 to 1
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func NewLossy(conn net.PacketConn, latency, jitter time.Duration, loss float64) *Lossy {
+	return &Lossy{
+		PacketConn: conn,
+		Latency:    latency,
+		Jitter:     jitter,
+		Loss:       loss,
+		rng:        rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (l *Lossy) WriteTo(b []byte, addr net.Addr) (int, error) {
+	l.mu.Lock()
+	drop := l.rng.Float64() < l.Loss
+	delay := l.Latency
+	if l.Jitter > 0 {
+		delay += time.Duration(l.rng.Int63n(int64(l.Jitter)))
+	}
+	l.mu.Unlock()
+
+	if drop {
+		return len(b), nil
+	}
+	if delay <= 0 {
+		return l.PacketConn.WriteTo(b, addr)
+	}
+
+	packet := append([]byte(nil), b...)
+	time.AfterFunc(delay, func() {
+		l.PacketConn.WriteTo(packet, addr)
+	})
+	return len(b), nil
+}
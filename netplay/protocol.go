@@ -0,0 +1,166 @@
+// Package netplay runs two player races over UDP. Both games simulate the same
+// seeded tower and only exchange inputs, every tick runs once the inputs of both
+// players for it arrived. Local input is delayed by a few ticks to hide latency.
+package netplay
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	MsgJoin byte = iota + 1
+	MsgStart
+	MsgInput
+	MsgHash
+	MsgLeave
+	MsgReject
+)
+
+const (
+	PLAYERS    = 2
+	MAX_PACKET = 512
+	MAX_INPUTS = 64 // inputs resent in one packet until the other side acknowledged them
+	MAX_HASHES = 32 // state hashes resent in one packet until the other side acknowledged them
+	ROOM_LEN   = 32
+)
+
+// Input bits of one tick
+const (
+	InputLeft byte = 1 << iota
+	InputRight
+	InputUp
+	InputDown
+	InputJump
+)
+
+var errShort = errors.New("packet too short")
+
+// Join asks the relay for a race, players are only paired when their mode and game data match
+type Join struct {
+	Room   string
+	Mode   byte
+	Config uint64 // hash of the game data the simulation depends on
+}
+
+// Start is sent by the relay once a room is full
+type Start struct {
+	Seed   int64
+	Player int
+	Delay  int
+	Mode   byte
+}
+
+// Inputs carries the inputs of one player from tick First on, Ack counts the inputs
+// the sender has of the receiver so it can stop resending them
+type Inputs struct {
+	Player int
+	Ack    uint32
+	First  uint32
+	Bits   []byte
+}
+
+type TickHash struct {
+	Tick uint32
+	Hash uint64
+}
+
+// Hashes carries the state hashes of one player the other has not acknowledged, oldest first.
+// Ack is one past the newest hash of the receiver the sender has, older ones need no resend
+type Hashes struct {
+	Player int
+	Ack    uint32
+	Hashes []TickHash
+}
+
+func EncodeJoin(j Join) []byte {
+	room := j.Room
+	if len(room) > ROOM_LEN {
+		room = room[:ROOM_LEN]
+	}
+	b := binary.LittleEndian.AppendUint64([]byte{MsgJoin, j.Mode}, j.Config)
+	return append(b, room...)
+}
+
+func DecodeJoin(b []byte) (Join, error) {
+	if len(b) < 11 {
+		return Join{}, errShort
+	}
+	return Join{
+		Mode:   b[1],
+		Config: binary.LittleEndian.Uint64(b[2:]),
+		Room:   string(b[10:]),
+	}, nil
+}
+
+func EncodeStart(s Start) []byte {
+	b := []byte{MsgStart, byte(s.Player), byte(s.Delay), s.Mode}
+	return binary.LittleEndian.AppendUint64(b, uint64(s.Seed))
+}
+
+func DecodeStart(b []byte) (Start, error) {
+	if len(b) < 12 {
+		return Start{}, errShort
+	}
+	return Start{
+		Player: int(b[1]),
+		Delay:  int(b[2]),
+		Mode:   b[3],
+		Seed:   int64(binary.LittleEndian.Uint64(b[4:])),
+	}, nil
+}
+
+// EncodeReject tells a player why the relay did not pair them
+func EncodeReject(reason string) []byte {
+	return append([]byte{MsgReject}, reason...)
+}
+
+func DecodeReject(b []byte) string {
+	return string(b[1:])
+}
+
+func EncodeInputs(in Inputs) []byte {
+	b := []byte{MsgInput, byte(in.Player)}
+	b = binary.LittleEndian.AppendUint32(b, in.Ack)
+	b = binary.LittleEndian.AppendUint32(b, in.First)
+	return append(b, in.Bits...)
+}
+
+func DecodeInputs(b []byte) (Inputs, error) {
+	if len(b) < 10 {
+		return Inputs{}, errShort
+	}
+	return Inputs{
+		Player: int(b[1]),
+		Ack:    binary.LittleEndian.Uint32(b[2:]),
+		First:  binary.LittleEndian.Uint32(b[6:]),
+		Bits:   append([]byte(nil), b[10:]...),
+	}, nil
+}
+
+func EncodeHashes(hs Hashes) []byte {
+	b := []byte{MsgHash, byte(hs.Player)}
+	b = binary.LittleEndian.AppendUint32(b, hs.Ack)
+	for _, h := range hs.Hashes {
+		b = binary.LittleEndian.AppendUint32(b, h.Tick)
+		b = binary.LittleEndian.AppendUint64(b, h.Hash)
+	}
+	return b
+}
+
+func DecodeHashes(b []byte) (Hashes, error) {
+	if len(b) < 6 || (len(b)-6)%12 != 0 {
+		return Hashes{}, errShort
+	}
+	hs := Hashes{
+		Player: int(b[1]),
+		Ack:    binary.LittleEndian.Uint32(b[2:]),
+	}
+	for b = b[6:]; len(b) > 0; b = b[12:] {
+		hs.Hashes = append(hs.Hashes, TickHash{
+			Tick: binary.LittleEndian.Uint32(b),
+			Hash: binary.LittleEndian.Uint64(b[4:]),
+		})
+	}
+	return hs, nil
+}
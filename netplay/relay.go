@@ -0,0 +1,166 @@
+package netplay
+
+import (
+	"log"
+	"math/rand"
+	"net"
+	"time"
+)
+
+const ROOM_IDLE = 30 * time.Second
+
+type room struct {
+	name    string
+	join    Join // of the first player, the second one has to match it
+	players []net.Addr
+	start   [PLAYERS]Start
+	seen    time.Time
+}
+
+// Relay pairs the first two players joining a room and forwards their packets,
+// players never need to reach each other directly
+type Relay struct {
+	Delay int // input delay handed to every race
+
+	conn   net.PacketConn
+	rooms  map[string]*room
+	byAddr map[string]*room
+	rng    *rand.Rand
+}
+
+func NewRelay(conn net.PacketConn, delay int) *Relay {
+	return &Relay{
+		Delay:  delay,
+		conn:   conn,
+		rooms:  make(map[string]*room),
+		byAddr: make(map[string]*room),
+		rng:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Serve runs until the connection is closed
+func (r *Relay) Serve() error {
+	buf := make([]byte, MAX_PACKET)
+	lastSweep := time.Now()
+
+	for {
+		r.conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, from, err := r.conn.ReadFrom(buf)
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			err = nil
+			n = 0
+		}
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			r.handle(buf[:n], from)
+		}
+
+		if time.Since(lastSweep) > time.Second {
+			r.sweep()
+			lastSweep = time.Now()
+		}
+	}
+}
+
+func (r *Relay) handle(b []byte, from net.Addr) {
+	if len(b) == 0 {
+		return
+	}
+
+	switch b[0] {
+	case MsgJoin:
+		j, err := DecodeJoin(b)
+		if err != nil || j.Room == "" {
+			return
+		}
+		r.join(j, from)
+
+	case MsgInput, MsgHash, MsgLeave:
+		rm, ok := r.byAddr[from.String()]
+		if !ok {
+			return
+		}
+		rm.seen = time.Now()
+		for _, p := range rm.players {
+			if p.String() != from.String() {
+				r.conn.WriteTo(b, p)
+			}
+		}
+		if b[0] == MsgLeave {
+			r.close(rm)
+		}
+	}
+}
+
+// join answers repeated joins with the same start so a lost start packet is sent again,
+// a player whose mode or game data differ from the one waiting is turned away
+func (r *Relay) join(j Join, from net.Addr) {
+	name := j.Room
+	if rm, ok := r.byAddr[from.String()]; ok {
+		rm.seen = time.Now()
+		for i, p := range rm.players {
+			if p.String() == from.String() && len(rm.players) == PLAYERS {
+				r.conn.WriteTo(EncodeStart(rm.start[i]), p)
+			}
+		}
+		return
+	}
+
+	rm, ok := r.rooms[name]
+	if !ok {
+		rm = &room{name: name, join: j}
+		r.rooms[name] = rm
+	}
+	if len(rm.players) >= PLAYERS {
+		log.Printf("Room %s is full, %s turned away", name, from)
+		return
+	}
+	switch {
+	case j.Mode != rm.join.Mode:
+		r.conn.WriteTo(EncodeReject("the other player races in another mode"), from)
+		return
+	case j.Config != rm.join.Config:
+		r.conn.WriteTo(EncodeReject("the other player has different game data"), from)
+		return
+	}
+
+	rm.players = append(rm.players, from)
+	rm.seen = time.Now()
+	r.byAddr[from.String()] = rm
+	log.Printf("%s joined room %s", from, name)
+
+	if len(rm.players) == PLAYERS {
+		seed := r.rng.Int63()
+		for i, p := range rm.players {
+			rm.start[i] = Start{Seed: seed, Player: i, Delay: r.Delay, Mode: rm.join.Mode}
+			r.conn.WriteTo(EncodeStart(rm.start[i]), p)
+		}
+		delete(r.rooms, name) //the name can be used by the next pair
+		log.Printf("Room %s started with seed %d", name, seed)
+	}
+}
+
+func (r *Relay) close(rm *room) {
+	for _, p := range rm.players {
+		delete(r.byAddr, p.String())
+	}
+	if r.rooms[rm.name] == rm {
+		delete(r.rooms, rm.name)
+	}
+}
+
+func (r *Relay) sweep() {
+	for _, rm := range r.byAddr {
+		if time.Since(rm.seen) > ROOM_IDLE {
+			log.Printf("Room %s closed after being idle", rm.name)
+			r.close(rm)
+		}
+	}
+	for _, rm := range r.rooms {
+		if time.Since(rm.seen) > ROOM_IDLE {
+			r.close(rm)
+		}
+	}
+}
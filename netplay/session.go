@@ -0,0 +1,282 @@
+package netplay
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	TIMEOUT      = 5 * time.Second
+	JOIN_EVERY   = 500 * time.Millisecond
+	DEFAULT_WAIT = 4 // ticks of input delay when the relay does not pick one
+)
+
+var ErrTimeout = errors.New("the other player stopped answering")
+
+type packet struct {
+	data []byte
+	from net.Addr
+}
+
+// Session is one side of a race, the game polls it once per tick from its own goroutine
+type Session struct {
+	Start   Start
+	Started bool
+	Err     error  // set once the session cannot go on
+	Desync  uint32 // first tick the state hashes differed, 0 while they agree
+	Tick    uint32 // next tick to simulate
+	Frame   [PLAYERS]byte
+	Prev    [PLAYERS]byte
+
+	conn      net.PacketConn
+	relay     net.Addr
+	join      Join
+	packets   chan packet
+	inputs    [PLAYERS][]byte
+	acked     uint32 // inputs of ours the other player has
+	hashes    [PLAYERS]map[uint32]uint64
+	pending   []TickHash // hashes of ours the other player has not acknowledged
+	heard     uint32     // one past the newest hash of the other player
+	ackDue    bool       // a hash arrived since the last hash packet went out
+	compared  uint32     // newest tick whose hashes were compared
+	lastHeard time.Time
+	lastJoin  time.Time
+}
+
+// Dial joins a room on the relay, the race starts once a second player with the same
+// mode and game data joins the same room
+func Dial(conn net.PacketConn, relay string, join Join) (*Session, error) {
+	addr, err := net.ResolveUDPAddr("udp", relay)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Session{
+		conn:      conn,
+		relay:     addr,
+		join:      join,
+		packets:   make(chan packet, 256),
+		lastHeard: time.Now(),
+	}
+	for i := range s.hashes {
+		s.hashes[i] = make(map[uint32]uint64)
+	}
+
+	go s.read()
+	return s, nil
+}
+
+func (s *Session) read() {
+	buf := make([]byte, MAX_PACKET)
+	for {
+		n, from, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			close(s.packets)
+			return
+		}
+		s.packets <- packet{append([]byte(nil), buf[:n]...), from}
+	}
+}
+
+func (s *Session) send(b []byte) {
+	s.conn.WriteTo(b, s.relay)
+}
+
+func (s *Session) Close() {
+	if s.Started {
+		s.send([]byte{MsgLeave})
+	}
+	s.conn.Close()
+}
+
+// Poll handles everything that arrived and keeps asking to join until the race starts
+func (s *Session) Poll() {
+	if s.Err != nil {
+		return
+	}
+
+	for {
+		select {
+		case p, ok := <-s.packets:
+			if !ok {
+				s.Err = errors.New("connection closed")
+				return
+			}
+			s.handle(p.data)
+			continue
+		default:
+		}
+		break
+	}
+
+	now := time.Now()
+	if !s.Started && now.Sub(s.lastJoin) > JOIN_EVERY {
+		s.send(EncodeJoin(s.join))
+		s.lastJoin = now
+	}
+	if s.Started && now.Sub(s.lastHeard) > TIMEOUT {
+		s.Err = ErrTimeout
+	}
+}
+
+func (s *Session) handle(b []byte) {
+	if len(b) == 0 {
+		return
+	}
+
+	switch b[0] {
+	case MsgStart:
+		if s.Started {
+			return
+		}
+		start, err := DecodeStart(b)
+		if err != nil || start.Player >= PLAYERS {
+			return
+		}
+		if start.Delay <= 0 {
+			start.Delay = DEFAULT_WAIT
+		}
+		s.Start = start
+		s.Started = true
+		s.lastHeard = time.Now()
+
+		//nobody presses anything during the delay at the start
+		for i := range s.inputs {
+			s.inputs[i] = make([]byte, start.Delay)
+		}
+
+	case MsgInput:
+		in, err := DecodeInputs(b)
+		if err != nil || !s.Started || in.Player >= PLAYERS || in.Player == s.Start.Player {
+			return
+		}
+		s.lastHeard = time.Now()
+		s.acked = max(s.acked, in.Ack)
+
+		have := &s.inputs[in.Player]
+		for i, bits := range in.Bits {
+			if in.First+uint32(i) == uint32(len(*have)) {
+				*have = append(*have, bits)
+			}
+		}
+
+	case MsgHash:
+		hs, err := DecodeHashes(b)
+		if err != nil || !s.Started || hs.Player >= PLAYERS || hs.Player == s.Start.Player {
+			return
+		}
+		s.lastHeard = time.Now()
+		s.ackDue = true
+
+		kept := s.pending[:0]
+		for _, h := range s.pending {
+			if h.Tick >= hs.Ack {
+				kept = append(kept, h)
+			}
+		}
+		s.pending = kept
+
+		for _, h := range hs.Hashes {
+			s.heard = max(s.heard, h.Tick+1)
+			if h.Tick > s.compared {
+				s.hashes[hs.Player][h.Tick] = h.Hash
+				s.compare(h.Tick)
+			}
+		}
+
+	case MsgLeave:
+		if s.Started {
+			s.Err = errors.New("the other player left")
+		}
+
+	case MsgReject:
+		if !s.Started {
+			s.Err = fmt.Errorf("relay refused the race: %s", DecodeReject(b))
+		}
+	}
+}
+
+func (s *Session) other() int {
+	return 1 - s.Start.Player
+}
+
+// Advance queues the local input and reports if the next tick can be simulated,
+// Frame and Prev then hold the inputs of every player for it
+func (s *Session) Advance(local byte) bool {
+	if !s.Started || s.Err != nil {
+		return false
+	}
+
+	mine := &s.inputs[s.Start.Player]
+	if uint32(len(*mine)) <= s.Tick+uint32(s.Start.Delay) {
+		*mine = append(*mine, local)
+	}
+
+	//everything the other side has not acknowledged, oldest first
+	first := min(s.acked, uint32(len(*mine)))
+	last := min(first+MAX_INPUTS, uint32(len(*mine)))
+	s.send(EncodeInputs(Inputs{
+		Player: s.Start.Player,
+		Ack:    uint32(len(s.inputs[s.other()])),
+		First:  first,
+		Bits:   (*mine)[first:last],
+	}))
+	if len(s.pending) > 0 || s.ackDue {
+		s.sendHashes()
+	}
+
+	for p := range s.inputs {
+		if uint32(len(s.inputs[p])) <= s.Tick {
+			return false
+		}
+	}
+
+	for p := range s.inputs {
+		s.Prev[p] = s.Frame[p]
+		s.Frame[p] = s.inputs[p][s.Tick]
+	}
+	s.Tick++
+	return true
+}
+
+// Check shares a hash of the simulation so both sides notice when they drift apart,
+// it goes out again with every input packet until the other side has it
+func (s *Session) Check(tick uint32, hash uint64) {
+	s.hashes[s.Start.Player][tick] = hash
+	s.pending = append(s.pending, TickHash{Tick: tick, Hash: hash})
+	s.sendHashes()
+	s.compare(tick)
+}
+
+func (s *Session) sendHashes() {
+	s.send(EncodeHashes(Hashes{
+		Player: s.Start.Player,
+		Ack:    s.heard,
+		Hashes: s.pending[:min(len(s.pending), MAX_HASHES)],
+	}))
+	s.ackDue = false
+}
+
+// compare drops every hash up to the compared tick, older ones can no longer be matched
+func (s *Session) compare(tick uint32) {
+	mine, ok := s.hashes[s.Start.Player][tick]
+	theirs, ok2 := s.hashes[s.other()][tick]
+	if !ok || !ok2 {
+		return
+	}
+	if mine != theirs && s.Desync == 0 {
+		s.Desync = tick
+		s.Err = fmt.Errorf("simulation went out of sync at tick %d", tick)
+	}
+
+	s.compared = max(s.compared, tick)
+	for _, hashes := range s.hashes {
+		for t := range hashes {
+			if t <= s.compared {
+				delete(hashes, t)
+			}
+		}
+	}
+}
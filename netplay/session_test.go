@@ -0,0 +1,201 @@
+package netplay
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startRelay serves a relay on loopback behind a lossy link until the test ends
+func startRelay(t *testing.T, latency, jitter time.Duration, loss float64) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go NewRelay(NewLossy(conn, latency, jitter, loss), DEFAULT_WAIT).Serve()
+	return conn.LocalAddr().String()
+}
+
+func dial(t *testing.T, relay string, j Join, latency, jitter time.Duration, loss float64) *Session {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Dial(NewLossy(conn, latency, jitter, loss), relay, j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestRaceOverLossyLoopback(t *testing.T) {
+	const (
+		ticks   = 300
+		latency = 20 * time.Millisecond
+		jitter  = 10 * time.Millisecond
+		loss    = 0.05
+	)
+	relay := startRelay(t, latency, jitter, loss)
+	join := Join{Room: "test", Mode: 1, Config: 42}
+	sessions := [PLAYERS]*Session{
+		dial(t, relay, join, latency, jitter, loss),
+		dial(t, relay, join, latency, jitter, loss),
+	}
+
+	streams := [PLAYERS][]byte{}
+	rng := rand.New(rand.NewSource(1))
+	deadline := time.Now().Add(30 * time.Second)
+
+	for done := false; !done; time.Sleep(time.Second / 120) {
+		if time.Now().After(deadline) {
+			t.Fatalf("only reached ticks %d and %d", sessions[0].Tick, sessions[1].Tick)
+		}
+
+		done = true
+		for i, s := range sessions {
+			s.Poll()
+			if s.Err != nil {
+				t.Fatalf("player %d: %v", i+1, s.Err)
+			}
+			if s.Tick >= ticks {
+				continue
+			}
+			done = false
+
+			if !s.Advance(byte(rng.Intn(32))) {
+				continue
+			}
+			streams[i] = append(streams[i], s.Frame[:]...)
+			if s.Tick%60 == 0 {
+				h := fnv.New64a()
+				h.Write(streams[i])
+				s.Check(s.Tick, h.Sum64())
+			}
+		}
+	}
+
+	if sessions[0].Start.Player == sessions[1].Start.Player {
+		t.Errorf("both players got slot %d", sessions[0].Start.Player)
+	}
+	for i, s := range sessions {
+		if s.Start.Mode != join.Mode {
+			t.Errorf("player %d races in mode %d, joined with %d", i+1, s.Start.Mode, join.Mode)
+		}
+	}
+	if sessions[0].Start.Seed != sessions[1].Start.Seed {
+		t.Error("the players got different seeds")
+	}
+	if string(streams[0]) != string(streams[1]) {
+		t.Error("the players saw different inputs")
+	}
+	for i, s := range sessions {
+		//every check but the newest few has to make it through the loss
+		if s.compared < ticks-120 {
+			t.Errorf("player %d only compared hashes up to tick %d", i+1, s.compared)
+		}
+		if len(s.hashes[0])+len(s.hashes[1]) > 4 {
+			t.Errorf("player %d keeps %d hashes", i+1, len(s.hashes[0])+len(s.hashes[1]))
+		}
+	}
+}
+
+// hashes lost on the way are sent again, so the desync is still found by the first side to have both
+func TestDesyncFoundDespiteLoss(t *testing.T) {
+	const loss = 0.4
+	relay := startRelay(t, 0, 0, loss)
+	join := Join{Room: "test", Mode: 1, Config: 42}
+	sessions := [PLAYERS]*Session{
+		dial(t, relay, join, 0, 0, loss),
+		dial(t, relay, join, 0, 0, loss),
+	}
+
+	deadline := time.Now().Add(20 * time.Second)
+	for sessions[0].Err == nil && sessions[1].Err == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("no desync found: %v, %v", sessions[0].Err, sessions[1].Err)
+		}
+		for i, s := range sessions {
+			s.Poll()
+			if s.Err == nil && s.Advance(0) && s.Tick == 30 {
+				s.Check(s.Tick, uint64(i)) // each side hashes differently
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	for i, s := range sessions {
+		if s.Err != nil && s.Desync != 30 {
+			t.Errorf("player %d: desync at %d, want 30 (%v)", i+1, s.Desync, s.Err)
+		}
+	}
+}
+
+func TestRelayRejectsMismatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		second Join
+		reason string
+	}{
+		{"mode", Join{Room: "test", Mode: 0, Config: 42}, "mode"},
+		{"game data", Join{Room: "test", Mode: 1, Config: 7}, "game data"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			relay := startRelay(t, 0, 0, 0)
+			first := dial(t, relay, Join{Room: "test", Mode: 1, Config: 42}, 0, 0, 0)
+			first.Poll()
+			time.Sleep(50 * time.Millisecond) // the first join has to reach the relay before the second
+
+			second := dial(t, relay, tt.second, 0, 0, 0)
+			deadline := time.Now().Add(5 * time.Second)
+			for second.Err == nil && time.Now().Before(deadline) {
+				first.Poll()
+				second.Poll()
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			if second.Err == nil || !strings.Contains(second.Err.Error(), tt.reason) {
+				t.Fatalf("second player got %v, want a refusal about %s", second.Err, tt.reason)
+			}
+			if first.Started {
+				t.Error("the first player started a race with a mismatched player")
+			}
+		})
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	j := Join{Room: "room", Mode: 1, Config: 0xdeadbeef}
+	if got, err := DecodeJoin(EncodeJoin(j)); err != nil || got != j {
+		t.Errorf("join came back as %+v, %v", got, err)
+	}
+
+	s := Start{Seed: -12345, Player: 1, Delay: 6, Mode: 1}
+	if got, err := DecodeStart(EncodeStart(s)); err != nil || got != s {
+		t.Errorf("start came back as %+v, %v", got, err)
+	}
+
+	in := Inputs{Player: 1, Ack: 10, First: 7, Bits: []byte{1, 2, 3}}
+	got, err := DecodeInputs(EncodeInputs(in))
+	if err != nil || got.Player != in.Player || got.Ack != in.Ack || got.First != in.First || string(got.Bits) != string(in.Bits) {
+		t.Errorf("inputs came back as %+v, %v", got, err)
+	}
+
+	hs := Hashes{Player: 1, Ack: 541, Hashes: []TickHash{{600, 99}, {660, 1 << 63}}}
+	gotHashes, err := DecodeHashes(EncodeHashes(hs))
+	if err != nil || gotHashes.Player != hs.Player || gotHashes.Ack != hs.Ack || len(gotHashes.Hashes) != 2 ||
+		gotHashes.Hashes[0] != hs.Hashes[0] || gotHashes.Hashes[1] != hs.Hashes[1] {
+		t.Errorf("hashes came back as %+v, %v", gotHashes, err)
+	}
+	if _, err := DecodeHashes(EncodeHashes(hs)[:10]); err == nil {
+		t.Error("a cut off hash packet was accepted")
+	}
+}
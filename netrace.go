@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"hash/fnv"
+	"log"
+	"math"
+	"net"
+	"time"
+
+	"github.com/AndriiPets/1Bit/netplay"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+const NET_CHECK_EVERY = 60 // ticks between state hashes
+
+type NetConfig struct {
+	Relay   string
+	Room    string
+	Latency time.Duration
+	Jitter  time.Duration
+	Loss    float64
+}
+
+// NetInput is the input of one player for the tick being simulated
+type NetInput struct {
+	Bits byte
+	Prev byte
+}
+
+var netBits = map[Action]byte{
+	ActionLeft:  netplay.InputLeft,
+	ActionRight: netplay.InputRight,
+	ActionUp:    netplay.InputUp,
+	ActionDown:  netplay.InputDown,
+	ActionJump:  netplay.InputJump,
+}
+
+// NetRace is an online head to head race, the tower is seeded by the relay
+type NetRace struct {
+	session *netplay.Session
+	config  NetConfig
+	inputs  [netplay.PLAYERS]NetInput
+	begun   bool
+	tuning  TuningValues // restored after the race, both sides race with the built in tuning
+}
+
+// raceConfig is a hash of the game data the simulation reads, mods or a changed
+// platforms.json would make the two sides build different towers
+func raceConfig() uint64 {
+	h := fnv.New64a()
+	enc := json.NewEncoder(h)
+	enc.Encode(DefaultTuning())
+	for _, t := range PlatformTypes {
+		enc.Encode(PlatformDefs[t])
+	}
+	for _, c := range Chunks {
+		enc.Encode(c)
+	}
+	return h.Sum64()
+}
+
+// Mode is the control mode both racers agreed on
+func (n *NetRace) Mode() ControlMode {
+	if n.session.Start.Mode == byte(Flying) {
+		return Flying
+	}
+	return Jumping
+}
+
+func (g *Game) joinRace(cfg NetConfig) {
+	conn, err := net.ListenPacket("udp", ":0")
+	if err != nil {
+		g.netStatus = err.Error()
+		log.Println("Cannot open a connection for the race:", err)
+		return
+	}
+
+	var pc net.PacketConn = conn
+	if cfg.Latency > 0 || cfg.Jitter > 0 || cfg.Loss > 0 {
+		pc = netplay.NewLossy(conn, cfg.Latency, cfg.Jitter, cfg.Loss)
+	}
+
+	s, err := netplay.Dial(pc, cfg.Relay, netplay.Join{Room: cfg.Room, Mode: byte(g.settings.Mode), Config: raceConfig()})
+	if err != nil {
+		conn.Close()
+		g.netStatus = err.Error()
+		log.Println("Cannot reach the relay:", err)
+		return
+	}
+
+	g.net = &NetRace{session: s, config: cfg}
+	g.netStatus = ""
+	g.title = true
+}
+
+// leaveRace goes back to the title screen, why is shown there when it is not empty
+func (g *Game) leaveRace(why string) {
+	if why != "" {
+		log.Println("Online race ended:", why)
+	}
+	g.net.session.Close()
+	if g.net.begun {
+		Tuning = g.net.tuning
+	}
+	g.net = nil
+	g.netStatus = why
+	g.seeded = false
+	g.Restart()
+	g.title = true
+}
+
+// localBits reads the first player's keys and gamepad
+func localBits() byte {
+	in := PlayerInput{Gamepad: 0}
+	var bits byte
+	for a, bit := range netBits {
+		if in.Pressed(a) {
+			bits |= bit
+		}
+	}
+	return bits
+}
+
+func (n *NetRace) Update(g *Game) {
+	s := n.session
+	s.Poll()
+
+	if g.runOver() && n.begun {
+		if inpututil.IsKeyJustPressed(Keys[ActionRestart]) {
+			g.leaveRace("")
+		}
+		return
+	}
+
+	if s.Err != nil {
+		g.leaveRace(s.Err.Error())
+		return
+	}
+	if !s.Started {
+		return
+	}
+
+	if !n.begun {
+		n.begun = true
+		n.tuning = Tuning
+		Tuning = DefaultTuning()
+		g.seed, g.seeded = s.Start.Seed, true
+		g.Restart()
+		g.title = false
+	}
+
+	if !s.Advance(localBits()) {
+		return
+	}
+	for i := range n.inputs {
+		n.inputs[i] = NetInput{Bits: s.Frame[i], Prev: s.Prev[i]}
+	}
+	g.step()
+
+	if s.Tick%NET_CHECK_EVERY == 0 {
+		s.Check(s.Tick, g.stateHash())
+	}
+}
+
+func (n *NetRace) Waiting() bool {
+	return !n.session.Started
+}
+
+func (n *NetRace) Local() int {
+	return n.session.Start.Player
+}
+
+// stateHash covers what the simulation decides, drawing and effects are left out
+func (g *Game) stateHash() uint64 {
+	h := fnv.New64a()
+	f := func(v float64) {
+		h.Write(binary.LittleEndian.AppendUint64(nil, math.Float64bits(v)))
+	}
+
+	f(g.score)
+	f(GameSpeed)
+	for _, p := range g.players {
+		f(p.Object.Position.X)
+		f(p.Object.Position.Y)
+		f(p.Speed.X)
+		f(p.Speed.Y)
+		if p.dead {
+			h.Write([]byte{1})
+		}
+	}
+	for _, p := range g.platformSpawner.Platforms {
+		if p != nil && p.used {
+			f(p.Object.Position.X)
+			f(p.Object.Position.Y)
+		}
+	}
+	return h.Sum64()
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// newTestGame loads the game like NewGame with default settings instead of the saved ones
+func newTestGame(t *testing.T, mode ControlMode) *Game {
+	t.Helper()
+	g := NewGame()
+	g.settings = DefaultSettings()
+	g.settings.Mode = mode
+	for !g.loader.Step() {
+	}
+	if len(g.loader.Errors) > 0 {
+		t.Fatal(g.loader.Errors)
+	}
+	g.loader = nil
+	return g
+}
+
+// race runs a seeded single player run on scripted inputs and returns the state hash every second
+func race(g *Game, seed int64, ticks int) []uint64 {
+	g.seed, g.seeded = seed, true
+	g.Restart()
+	g.title = false
+
+	var in NetInput
+	g.player.Input = PlayerInput{Net: &in}
+	rng := rand.New(rand.NewSource(seed))
+
+	var hashes []uint64
+	for tick := 1; tick <= ticks; tick++ {
+		in.Prev, in.Bits = in.Bits, byte(rng.Intn(32))
+		g.step()
+		if tick%NET_CHECK_EVERY == 0 {
+			hashes = append(hashes, g.stateHash())
+		}
+	}
+	return hashes
+}
+
+// TestStepDeterministic is what online races rely on, the same seed and inputs give the same state
+func TestStepDeterministic(t *testing.T) {
+	for _, mode := range []ControlMode{Jumping, Flying} {
+		t.Run(mode.String(), func(t *testing.T) {
+			first := race(newTestGame(t, mode), 7, 600)
+			second := race(newTestGame(t, mode), 7, 600)
+
+			for i := range first {
+				if first[i] != second[i] {
+					t.Fatalf("state went apart by tick %d", (i+1)*NET_CHECK_EVERY)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"image"
+	"log"
+	"math/rand"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+type Emitter struct {
+	Lifetime [2]int // ticks, min and max
+	Velocity Vec2
+	Spread   Vec2 // random velocity added in both directions
+	Gravity  float64
+	Scroll   bool // particle moves down with the tower like the platforms do
+	frames   []*ebiten.Image
+}
+
+// NewEmitter plays the given atlas frames over the lifetime of every particle
+func NewEmitter(frames []*image.Rectangle, lifetime [2]int, velocity, spread Vec2, gravity float64, scroll bool) *Emitter {
+	e := &Emitter{
+		Lifetime: lifetime,
+		Velocity: velocity,
+		Spread:   spread,
+		Gravity:  gravity,
+		Scroll:   scroll,
+	}
+
+	for _, f := range frames {
+		e.frames = append(e.frames, Atlas.SubImage(*f).(*ebiten.Image))
+	}
+
+	return e
+}
+
+type Particle struct {
+	Pos     Vec2
+	Vel     Vec2
+	age     int
+	life    int
+	emitter *Emitter
+	proj    Projection
+}
+
+type ParticleSystem struct {
+	Particles []Particle
+	next      int
+	Reduced   bool // accessibility setting, emits a quarter of the particles
+
+	Trail  *Emitter
+	Debris *Emitter
+	Sparks *Emitter
+}
+
+func NewParticleSystem(size int) *ParticleSystem {
+	ps := &ParticleSystem{
+		Particles: make([]Particle, size),
+	}
+
+	ps.setupEmitters()
+
+	return ps
+}
+
+// setupEmitters takes the frames from the atlas, live particles keep the emitter they started with
+func (ps *ParticleSystem) setupEmitters() {
+	frames := func(name string) []*image.Rectangle {
+		f, err := AtlasFrames(name)
+		if err != nil {
+			log.Println("Particles have no frames:", err)
+		}
+		return f
+	}
+	ps.Trail = NewEmitter(frames("particle_trail"), [2]int{20, 40}, Vec2{0, 0}, Vec2{0.3, 0.2}, 0, true)
+	ps.Debris = NewEmitter(frames("particle_debris"), [2]int{40, 90}, Vec2{0, -3}, Vec2{3, 2}, 0.15, false)
+	ps.Sparks = NewEmitter(frames("particle_spark"), [2]int{8, 16}, Vec2{0, 0.5}, Vec2{1, 0.5}, 0.1, true)
+}
+
+// Emit reuses the oldest slots once the pool is full
+func (ps *ParticleSystem) Emit(e *Emitter, pos Vec2, count int) {
+	if len(e.frames) == 0 {
+		return
+	}
+	if ps.Reduced {
+		if rand.Intn(4) != 0 && count < 4 {
+			return
+		}
+		count = max(1, count/4)
+	}
+
+	for range count {
+		p := &ps.Particles[ps.next]
+		ps.next = (ps.next + 1) % len(ps.Particles)
+
+		*p = Particle{
+			Pos: pos,
+			Vel: Vec2{
+				e.Velocity[0] + (rand.Float64()*2-1)*e.Spread[0],
+				e.Velocity[1] + (rand.Float64()*2-1)*e.Spread[1],
+			},
+			life:    e.Lifetime[0] + rand.Intn(e.Lifetime[1]-e.Lifetime[0]+1),
+			emitter: e,
+		}
+	}
+}
+
+func (ps *ParticleSystem) Clear() {
+	for i := range ps.Particles {
+		ps.Particles[i].emitter = nil
+	}
+}
+
+func (ps *ParticleSystem) Update(center float64) {
+	for i := range ps.Particles {
+		p := &ps.Particles[i]
+		if p.emitter == nil {
+			continue
+		}
+
+		p.age++
+		if p.age >= p.life {
+			p.emitter = nil
+			continue
+		}
+
+		p.Vel[1] += p.emitter.Gravity
+		p.Pos[0] += p.Vel[0]
+		p.Pos[1] += p.Vel[1]
+		if p.emitter.Scroll {
+			p.Pos[1] += GameSpeed
+		}
+	}
+	ps.Project(center)
+}
+
+// Project places the live particles for a view centered on the given x
+func (ps *ParticleSystem) Project(center float64) {
+	for i := range ps.Particles {
+		p := &ps.Particles[i]
+		if p.emitter == nil {
+			continue
+		}
+		w, h := p.frame().Bounds().Dx(), p.frame().Bounds().Dy()
+		p.proj = projectRect(p.Pos[0], p.Pos[1], float64(w), float64(h), center)
+	}
+}
+
+func (p *Particle) frame() *ebiten.Image {
+	frames := p.emitter.frames
+	return frames[p.age*len(frames)/p.life]
+}
+
+// Draw draws the particles projected onto the given layer
+func (ps *ParticleSystem) Draw(world *ebiten.Image, layer LayerID) {
+	for i := range ps.Particles {
+		p := &ps.Particles[i]
+		if p.emitter == nil || p.proj.Layer != layer || p.proj.Scale <= 0 {
+			continue
+		}
+
+		img := p.frame()
+		dst := p.proj.target(world)
+
+		if p.proj.Behind && DitherShader != nil {
+			op := &ebiten.DrawRectShaderOptions{}
+			op.GeoM.Scale(p.proj.Scale, 1)
+			op.GeoM.Translate(p.proj.DrawPos[0], p.proj.DrawPos[1])
+			op.Images[0] = img
+			op.Uniforms = map[string]interface{}{"Depth": float32(p.proj.Depth)}
+			dst.DrawRectShader(img.Bounds().Dx(), img.Bounds().Dy(), DitherShader, op)
+			continue
+		}
+
+		op := &ebiten.DrawImageOptions{}
+		op.GeoM.Scale(p.proj.Scale, 1)
+		op.GeoM.Translate(p.proj.DrawPos[0], p.proj.DrawPos[1])
+		dst.DrawImage(img, op)
+	}
+}
+
+func (ps *ParticleSystem) Listen(bus *Bus) {
+	Subscribe(bus, func(e PlayerDied) { ps.Emit(ps.Debris, e.Pos, 24) })
+	Subscribe(bus, func(e NearMiss) { ps.Emit(ps.Sparks, e.Pos, 4+2*e.Combo) })
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"image"
+
+	"github.com/AndriiPets/1Bit/netplay"
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const (
+	MAX_PLAYERS  = 4
+	REVIVE_TICKS = 90
+	REVIVE_RANGE = 12 // pixels between two players that still count as standing together
+	SHIELD_TICKS = 120
+)
+
+type PartyMode int
+
+const (
+	Coop PartyMode = iota
+	Versus
+)
+
+func (m PartyMode) String() string {
+	if m == Versus {
+		return "versus"
+	}
+	return "coop"
+}
+
+// extraKeys are the keyboard bindings of players 2 to 4, player 1 uses the settings.
+// They stay off the keys the game reads itself: Q E R P M S D T, the arrows, Z and the F keys.
+var extraKeys = []KeyBindings{
+	{ActionLeft: ebiten.KeyJ, ActionRight: ebiten.KeyL, ActionUp: ebiten.KeyI, ActionDown: ebiten.KeyK, ActionJump: ebiten.KeySpace},
+	{ActionLeft: ebiten.KeyNumpad4, ActionRight: ebiten.KeyNumpad6, ActionUp: ebiten.KeyNumpad8, ActionDown: ebiten.KeyNumpad5, ActionJump: ebiten.KeyNumpad0},
+	{ActionLeft: ebiten.KeyDelete, ActionRight: ebiten.KeyPageDown, ActionUp: ebiten.KeyHome, ActionDown: ebiten.KeyEnd, ActionJump: ebiten.KeyInsert},
+}
+
+// View is one player's part of the canvas, the tower is centered on that player
+type View struct {
+	Player     *Player
+	Camera     *Camera
+	Background *TowerBackground
+	Rect       image.Rectangle
+	hud        *UIScreen
+}
+
+// viewRects splits the canvas in halves for two players and in quarters for more
+func viewRects(n int) []image.Rectangle {
+	full := image.Rect(0, 0, SCREEN_WIDTH, SCREEN_HEIGHT)
+	switch n {
+	case 1:
+		return []image.Rectangle{full}
+	case 2:
+		return []image.Rectangle{
+			image.Rect(0, 0, HALF_WIDTH, SCREEN_HEIGHT),
+			image.Rect(HALF_WIDTH, 0, SCREEN_WIDTH, SCREEN_HEIGHT),
+		}
+	}
+	rects := []image.Rectangle{
+		image.Rect(0, 0, HALF_WIDTH, HALF_HEIGHT),
+		image.Rect(HALF_WIDTH, 0, SCREEN_WIDTH, HALF_HEIGHT),
+		image.Rect(0, HALF_HEIGHT, HALF_WIDTH, SCREEN_HEIGHT),
+		image.Rect(HALF_WIDTH, HALF_HEIGHT, SCREEN_WIDTH, SCREEN_HEIGHT),
+	}
+	return rects[:n]
+}
+
+// localPlayer is the one sitting at this machine, the first one outside online races
+func (g *Game) localPlayer() *Player {
+	if g.net != nil {
+		return g.players[g.net.Local()]
+	}
+	return g.player
+}
+
+// lowestPlayer is the living player furthest down the tower, platforms are kept until they pass it.
+// With everyone out it is the lowest of all.
+func (g *Game) lowestPlayer() *Player {
+	var lowest *Player
+	for _, p := range g.players {
+		if !p.dead && (lowest == nil || p.Object.Bottom() > lowest.Object.Bottom()) {
+			lowest = p
+		}
+	}
+	if lowest != nil {
+		return lowest
+	}
+	for _, p := range g.players {
+		if lowest == nil || p.Object.Bottom() > lowest.Object.Bottom() {
+			lowest = p
+		}
+	}
+	return lowest
+}
+
+// runMode is the control mode the players start in, online races use the agreed one
+// and a shared ghost is raced in its own mode
+func (g *Game) runMode(players int) ControlMode {
+	if g.net != nil && g.net.session.Started {
+		return g.net.Mode()
+	}
+	if g.racingShared(players) {
+		mode, _ := controlModeByName(g.sharedGhost.Mode)
+		return mode
+	}
+	return g.settings.Mode
+}
+
+// setupParty replaces the players and their views with the number in the settings
+func (g *Game) setupParty() {
+	for i, p := range g.players {
+		g.space.Remove(p.Object)
+		delete(g.sprites, playerSlot(i))
+	}
+	g.players = nil
+	g.views = nil
+
+	n := max(1, min(g.settings.Players, MAX_PLAYERS))
+	if g.net != nil {
+		n = netplay.PLAYERS
+	}
+	if g.daily != nil {
+		n = 1
+	}
+	for i, rect := range viewRects(n) {
+		p := NewPlayer(g, Vec2{startPos[0] + float64(i*24), startPos[1]}, playerSlot(i))
+		p.controls = g.runMode(n)
+		p.Index = i
+		p.Local = i == 0
+		if g.net != nil {
+			p.Local = i == g.net.Local()
+		}
+		if i > 0 {
+			p.Input = PlayerInput{Keys: extraKeys[i-1], Gamepad: i - 1}
+		} else if n == 1 {
+			p.Input.Gamepad = 0
+		}
+		if g.net != nil {
+			p.Input = PlayerInput{Net: &g.net.inputs[i]}
+		}
+
+		cam := NewCamera(
+			Vec2{float64(rect.Dx()), float64(rect.Dy())},
+			Vec2{WORLD_WIDTH, WORLD_HEIGTH + HALF_HEIGHT},
+			startPos,
+			2,
+			g.settings.PixelPerfect,
+		)
+		cam.ShakeScale = g.settings.ScreenShake
+
+		v := &View{
+			Player:     p,
+			Camera:     cam,
+			Background: NewBackground(ebiten.NewImage(TOWER_WIDTH, SCREEN_HEIGHT+HALF_HEIGHT)),
+			Rect:       rect,
+		}
+		if n > 1 {
+			v.hud = NewViewHUD(g, i, p)
+		}
+
+		g.players = append(g.players, p)
+		g.views = append(g.views, v)
+	}
+
+	//single player code keeps working on the first player
+	g.player = g.players[0]
+	g.camera = g.views[0].Camera
+	g.background = g.views[0].Background
+}
+
+func playerSlot(i int) int {
+	return 100 + i
+}
+
+// party is versus for online races whatever the settings say
+func (g *Game) party() PartyMode {
+	if g.net != nil {
+		return Versus
+	}
+	return g.settings.Party
+}
+
+func (g *Game) alive() int {
+	n := 0
+	for _, p := range g.players {
+		if !p.dead {
+			n++
+		}
+	}
+	return n
+}
+
+// runOver ends co-op when everyone fell and versus when one player is left standing
+func (g *Game) runOver() bool {
+	if len(g.players) > 1 && g.party() == Versus {
+		return g.alive() <= 1
+	}
+	return g.alive() == 0
+}
+
+// winner is the last player standing in versus, -1 otherwise
+func (g *Game) winner() int {
+	if len(g.players) < 2 || g.party() != Versus {
+		return -1
+	}
+	for i, p := range g.players {
+		if !p.dead {
+			return i
+		}
+	}
+	return -1
+}
+
+// revive brings a fallen co-op partner back once someone stood next to them long enough
+func (g *Game) revive() {
+	if g.party() != Coop {
+		return
+	}
+
+	for _, p := range g.players {
+		if !p.dead {
+			continue
+		}
+
+		near := false
+		for _, q := range g.players {
+			if q != p && !q.dead {
+				a := p.Object.Position
+				b := q.Object.Position
+				dx, dy := a.X-b.X, a.Y-b.Y
+				near = near || dx*dx+dy*dy <= (p.Object.Size.X+REVIVE_RANGE)*(p.Object.Size.X+REVIVE_RANGE)
+			}
+		}
+
+		if !near {
+			p.revive = 0
+			continue
+		}
+
+		p.revive++
+		if p.revive >= REVIVE_TICKS {
+			p.dead = false
+			p.revive = 0
+			p.shield = SHIELD_TICKS
+			g.audio.Play(SoundPickup)
+			obj := p.Object
+			g.particles.Emit(g.particles.Sparks, Vec2{obj.Position.X + obj.Size.X/2, obj.Position.Y}, 12)
+		}
+	}
+}
+
+// listenCameras shakes the view of a player who died, a new difficulty shakes every view
+func (g *Game) listenCameras(bus *Bus) {
+	Subscribe(bus, func(e PlayerDied) {
+		if e.Player < len(g.views) {
+			g.views[e.Player].Camera.Shake(1)
+		}
+	})
+	Subscribe(bus, func(DifficultyRaised) { g.shake(0.3) })
+}
+
+func (g *Game) shake(trauma float64) {
+	for _, v := range g.views {
+		v.Camera.Shake(trauma)
+	}
+}
+
+// drawView projects everything around the player of the view before drawing it into its part of the canvas
+func (g *Game) drawView(v *View, canvas *ebiten.Image) {
+	screen := canvas.SubImage(v.Rect).(*ebiten.Image)
+	center := v.Player.Object.Position.X
+
+	for _, s := range g.sprites {
+		if s.Object != nil {
+			s.Project(center)
+		}
+	}
+	g.particles.Project(center)
+	if g.ghost != nil {
+		g.ghost.Project(center)
+	}
+
+	g.world.Clear()
+
+	for _, s := range g.sprites {
+		if s.Object != nil && s.Layer == BehindTower {
+			s.Draw(g.world)
+		}
+	}
+
+	g.particles.Draw(g.world, BehindTower)
+	if g.ghost != nil && !g.title {
+		g.ghost.Draw(g.world, BehindTower)
+	}
+
+	v.Background.Draw(g.world, v.Player.Object.Position.X, v.Player.Object.Position.Y)
+
+	if !g.title {
+		for _, s := range g.sprites {
+			if s.Object != nil && s.Layer == BeforeTower {
+				s.Draw(g.world)
+			}
+		}
+
+		g.particles.Draw(g.world, BeforeTower)
+		if g.ghost != nil {
+			g.ghost.Draw(g.world, BeforeTower)
+		}
+		g.popups.Draw(g.world, center)
+	}
+
+	if g.debug {
+		g.overlay.DrawWorld(g.world)
+	}
+
+	v.Camera.Render(g.world, screen)
+	if v.hud != nil {
+		v.hud.Draw(screen)
+	}
+}
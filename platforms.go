@@ -1,13 +1,15 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
 	"math/rand"
 	"time"
 
 	rv "github.com/solarlune/resolv"
 	"github.com/tanema/gween"
 	"github.com/tanema/gween/ease"
-	"github.com/yohamta/ganim8/v2"
 )
 
 type Platform struct {
@@ -15,6 +17,7 @@ type Platform struct {
 	Sprite Sprite
 	used   bool
 	pType  string
+	kind   PlatformType
 	tween  *gween.Sequence
 }
 
@@ -25,45 +28,109 @@ const (
 	PlatformMoveHorizontal
 )
 
-func NewPlatform(game *Game, pos Vec2, tag string, pType PlatformType, inx int) *Platform {
-	var sizeX, sizeY float64
-	var tween *gween.Sequence
-	var anim *ganim8.Animation
+// PlatformTypes lists the types in a fixed order so weighted picks are repeatable
+var PlatformTypes = []PlatformType{PlatformNormal, PlatformMoveHorizontal}
 
-	switch pType {
-	case PlatformNormal:
-		sizeX, sizeY = 32, 16
-		tween = gween.NewSequence()
-		tween.Add(
-			gween.New(float32(pos[1]), float32(pos[1]), 2, ease.Linear),
-		)
+var platformNames = map[PlatformType]string{
+	PlatformNormal:         "normal",
+	PlatformMoveHorizontal: "moveh",
+}
+
+func (t PlatformType) String() string {
+	return platformNames[t]
+}
 
-		grid := ganim8.NewGrid(32, 16, AtlasW, AtlasH, 192, 16)
-		anim = ganim8.New(Atlas, grid.Frames(1, "1-1"), time.Millisecond*30)
+// PlatformDef is the data driven part of a platform type, loaded from assets/platforms.json
+type PlatformDef struct {
+	Size      Vec2      `json:"size"`
+	Animation string    `json:"animation"`
+	Path      []float64 `json:"path"` // horizontal offsets from the spawn point visited in order
+	Leg       float64   `json:"leg"`  // seconds between two points of the path
+	Weight    int       `json:"weight"`
+}
+
+var PlatformDefs map[PlatformType]PlatformDef
 
-	case PlatformMoveHorizontal:
-		sizeX, sizeY = 16, 16
-		tween = gween.NewSequence()
-		tween.Add(
-			gween.New(float32(pos[0]), float32(pos[0]+128), 2, ease.Linear),
-			gween.New(float32(pos[0]+128), float32(pos[0]), 2, ease.Linear),
-		)
+// worldRand lays out the platforms, effects keep using the global source so a seed gives the same tower
+var worldRand = rand.New(rand.NewSource(time.Now().UnixNano()))
 
-		grid := ganim8.NewGrid(16, 16, AtlasW, AtlasH, 192)
-		anim = ganim8.New(Atlas, grid.Frames("1-2", 1), time.Second)
+func SeedWorld(seed int64) {
+	worldRand.Seed(seed)
+}
+
+// usePlatformDefaults matches the shipped assets/platforms.json
+func usePlatformDefaults() {
+	PlatformDefs = map[PlatformType]PlatformDef{
+		PlatformNormal:         {Size: Vec2{32, 16}, Animation: "platform", Path: []float64{0}, Leg: 2, Weight: 7},
+		PlatformMoveHorizontal: {Size: Vec2{16, 16}, Animation: "mover", Path: []float64{0, 128, 0}, Leg: 2, Weight: 3},
+	}
+}
+
+func loadPlatformDefs() error {
+	b, err := fs.ReadFile(Assets, "assets/platforms.json")
+	if err != nil {
+		return err
+	}
+
+	var byName map[string]PlatformDef
+	if err := json.Unmarshal(b, &byName); err != nil {
+		return err
+	}
+
+	defs := make(map[PlatformType]PlatformDef)
+	for _, t := range PlatformTypes {
+		def, ok := byName[t.String()]
+		if !ok {
+			return fmt.Errorf("platform %s is not defined", t)
+		}
+		if def.Leg <= 0 {
+			def.Leg = 1
+		}
+		if Animations != nil {
+			if _, ok := Animations.Animations[def.Animation]; !ok {
+				return fmt.Errorf("platform %s uses the unknown animation %s", t, def.Animation)
+			}
+		}
+		defs[t] = def
+		delete(byName, t.String())
+	}
+	for name := range byName {
+		return fmt.Errorf("unknown platform %s", name)
+	}
+
+	PlatformDefs = defs
+	return nil
+}
+
+// NewPlatform follows the path of its type unless path is given
+func NewPlatform(game *Game, pos Vec2, tag string, pType PlatformType, inx int, path []float64) *Platform {
+	def := PlatformDefs[pType]
+
+	tween := gween.NewSequence()
+	if path == nil {
+		path = def.Path
+	}
+	//platforms that do not move keep their original placement, the tween holds their X at the spawn Y
+	if len(path) < 2 {
+		tween.Add(gween.New(float32(pos[1]), float32(pos[1]), float32(def.Leg), ease.Linear))
+	}
+	for i := range len(path) - 1 {
+		from, to := float32(pos[0]+path[i]), float32(pos[0]+path[i+1])
+		tween.Add(gween.New(from, to, float32(def.Leg), ease.Linear))
 	}
 
 	p := &Platform{
-		Object: rv.NewObject(pos[0], pos[1], sizeX, sizeY, tag),
+		Object: rv.NewObject(pos[0], pos[1], def.Size[0], def.Size[1], tag),
 		pType:  tag,
+		kind:   pType,
 	}
+	p.Object.Data = p
 	//p.Object.SetShape(rv.NewRectangle(0, 0, p.Object.Size.X, p.Object.Size.Y))
 	game.space.Add(p.Object)
 
 	p.Sprite = Sprite{
 		Object:    p.Object,
-		Layer:     BeforeTower,
-		Animation: anim,
+		Animation: animationOrBlank(def.Animation),
 	}
 
 	p.tween = tween
@@ -73,7 +140,8 @@ func NewPlatform(game *Game, pos Vec2, tag string, pType PlatformType, inx int)
 	return p
 }
 
-func (p *Platform) Update(posY float64) {
+// Update moves the platform with the tower, it is shared by every player so nothing here depends on one of them
+func (p *Platform) Update() {
 
 	x, _, seqDone := p.tween.Update(1.0 / 60.0)
 	//p.Object.Position.Y = float64(y)
@@ -102,11 +170,16 @@ func NewPlatformSpawner(game *Game, size int) *PlatformSpawner {
 }
 
 func (ps *PlatformSpawner) Spawn(pos Vec2, pType PlatformType, tags string) {
+	ps.SpawnPath(pos, pType, tags, nil)
+}
+
+func (ps *PlatformSpawner) SpawnPath(pos Vec2, pType PlatformType, tags string, path []float64) {
 	for inx, p := range ps.Platforms {
 		if p == nil || !p.used {
-			platform := NewPlatform(ps.Game, pos, tags, pType, inx)
+			platform := NewPlatform(ps.Game, pos, tags, pType, inx, path)
 			platform.used = true
 			ps.Platforms[inx] = platform
+			Publish(ps.Game.events, PlatformSpawned{Slot: inx, Type: pType, Pos: pos})
 			return
 		}
 	}
@@ -115,31 +188,41 @@ func (ps *PlatformSpawner) Spawn(pos Vec2, pType PlatformType, tags string) {
 func (ps *PlatformSpawner) Update() {
 
 	var spawnAreaCount int
+	lowest := ps.Game.lowestPlayer()
 
 	for inx, p := range ps.Platforms {
 		if p != nil && p.used {
-			p.Update(ps.Game.player.Ypos)
+			p.Update()
+
+			if p.kind == PlatformMoveHorizontal && rand.Intn(8) == 0 {
+				sparks := ps.Game.particles
+				sparks.Emit(sparks.Sparks, Vec2{p.Object.Position.X + rand.Float64()*p.Object.Size.X, p.Object.Bottom()}, 1)
+			}
 
 			if p.Object.Position.Y < SCREEN_HEIGHT {
 				spawnAreaCount++
 			}
 
-			if p.Object.Position.Y > ps.Game.player.Object.Bottom()+HALF_HEIGHT {
-				ps.Release(inx)
+			if p.Object.Position.Y > lowest.Object.Bottom()+HALF_HEIGHT {
+				ps.Release(inx, true)
 				//fmt.Println("Platform destroyed", inx)
 			}
 		}
 	}
 
 	if spawnAreaCount < 1 {
-		ps.Generate(15 + Difficulty)
+		if c := pickChunk(); c != nil {
+			ps.SpawnChunk(c)
+			return
+		}
+		ps.Generate(Tuning.WaveSize + Difficulty)
 		//ps.Game.fillPockets(SCREEN_HEIGHT)
 	}
 }
 
 func (ps PlatformSpawner) Sweep() {
 	for inx := range ps.Platforms {
-		ps.Release(inx)
+		ps.Release(inx, false)
 	}
 }
 
@@ -151,25 +234,39 @@ func (ps *PlatformSpawner) Generate(ammount int) {
 	for i := range ammount {
 		for range 3 { //attempt to find coordinates again if failed
 
-			cx, cy := rand.Intn(boundX), rand.Intn(boundY)
+			cx, cy := worldRand.Intn(boundX), worldRand.Intn(boundY)
 			coord := Vec2_i{cx, cy}
 
 			if checkCoords(taken, coord) {
 				taken[coord] = i
 				pos := Vec2{float64(TOWER_OFFSET + cx*TILE_SIZE), float64(cy * TILE_SIZE)}
-				dice := rand.Intn(10)
-				if dice < 3 {
-					ps.Spawn(pos, PlatformMoveHorizontal, "platform")
-				} else {
-
-					ps.Spawn(pos, PlatformNormal, "platform")
-				}
+				ps.Spawn(pos, pickPlatformType(), "platform")
 				break
 			}
 		}
 	}
 }
 
+// pickPlatformType rolls a type by the weights in the definitions
+func pickPlatformType() PlatformType {
+	total := 0
+	for _, t := range PlatformTypes {
+		total += PlatformDefs[t].Weight
+	}
+	if total <= 0 {
+		return PlatformNormal
+	}
+
+	dice := worldRand.Intn(total)
+	for _, t := range PlatformTypes {
+		dice -= PlatformDefs[t].Weight
+		if dice < 0 {
+			return t
+		}
+	}
+	return PlatformNormal
+}
+
 func checkCoords(taken map[Vec2_i]int, coord Vec2_i) bool {
 	nearCells := []Vec2_i{
 		{coord[0], coord[1]},
@@ -193,9 +290,13 @@ func checkCoords(taken map[Vec2_i]int, coord Vec2_i) bool {
 	return true
 }
 
-func (ps *PlatformSpawner) Release(inx int) {
+// Release takes a platform out of the world, passed tells if it scrolled away or was swept
+func (ps *PlatformSpawner) Release(inx int, passed bool) {
 	p := ps.Platforms[inx]
 	if p != nil {
+		if p.used {
+			Publish(ps.Game.events, PlatformReleased{Slot: inx, Type: p.kind, Passed: passed})
+		}
 		ps.Game.space.Remove(p.Object)
 		delete(ps.Game.sprites, inx)
 		p.used = false
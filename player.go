@@ -2,12 +2,10 @@ package main
 
 import (
 	"math"
-	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	rv "github.com/solarlune/resolv"
-	"github.com/yohamta/ganim8/v2"
 )
 
 type ControlMode int
@@ -17,6 +15,80 @@ const (
 	Flying
 )
 
+func (c ControlMode) String() string {
+	if c == Jumping {
+		return "jumping"
+	}
+	return "flying"
+}
+
+func controlModeByName(name string) (ControlMode, bool) {
+	for _, c := range []ControlMode{Jumping, Flying} {
+		if c.String() == name {
+			return c, true
+		}
+	}
+	return Jumping, false
+}
+
+// PlayerInput reads one player's actions from their keys and optionally a gamepad
+type PlayerInput struct {
+	Keys    KeyBindings // nil reads the bindings from the settings
+	Gamepad int         // position among the connected gamepads, -1 for none
+	Net     *NetInput   // set in online races, replaces keys and gamepad
+}
+
+// gamepadButtons are the standard layout buttons every action also listens to
+var gamepadButtons = map[Action]ebiten.StandardGamepadButton{
+	ActionLeft:  ebiten.StandardGamepadButtonLeftLeft,
+	ActionRight: ebiten.StandardGamepadButtonLeftRight,
+	ActionUp:    ebiten.StandardGamepadButtonLeftTop,
+	ActionDown:  ebiten.StandardGamepadButtonLeftBottom,
+	ActionJump:  ebiten.StandardGamepadButtonRightBottom,
+}
+
+func (in PlayerInput) key(a Action) ebiten.Key {
+	if in.Keys == nil {
+		return Keys[a]
+	}
+	return in.Keys[a]
+}
+
+func (in PlayerInput) gamepad() (ebiten.GamepadID, bool) {
+	if in.Gamepad < 0 {
+		return 0, false
+	}
+	ids := ebiten.AppendGamepadIDs(nil)
+	if in.Gamepad >= len(ids) || !ebiten.IsStandardGamepadLayoutAvailable(ids[in.Gamepad]) {
+		return 0, false
+	}
+	return ids[in.Gamepad], true
+}
+
+func (in PlayerInput) Pressed(a Action) bool {
+	if in.Net != nil {
+		return in.Net.Bits&netBits[a] != 0
+	}
+	if ebiten.IsKeyPressed(in.key(a)) {
+		return true
+	}
+	b, ok := gamepadButtons[a]
+	id, found := in.gamepad()
+	return ok && found && ebiten.IsStandardGamepadButtonPressed(id, b)
+}
+
+func (in PlayerInput) JustPressed(a Action) bool {
+	if in.Net != nil {
+		return in.Net.Bits&netBits[a] != 0 && in.Net.Prev&netBits[a] == 0
+	}
+	if inpututil.IsKeyJustPressed(in.key(a)) {
+		return true
+	}
+	b, ok := gamepadButtons[a]
+	id, found := in.gamepad()
+	return ok && found && inpututil.IsStandardGamepadButtonJustPressed(id, b)
+}
+
 type Player struct {
 	Object         *rv.Object
 	Ypos           float64
@@ -25,39 +97,53 @@ type Player struct {
 	IgnorePlatform *rv.Object
 	Sprite         Sprite
 	FacingRight    bool
+	Impact         float64
+	Jumped         bool
+	God            bool // console cheat, platforms cannot crush the player
+	Input          PlayerInput
+	Score          float64 // own score in versus, the run score is shared otherwise
+	shield         int     // ticks left where platforms cannot crush the player, after a revive
+	revive         int     // ticks a partner has stood next to this fallen player
 	controls       ControlMode
 	stuck          bool
 	dead           bool
+	events         *Bus
+	Index          int  // position in the party
+	Local          bool // sits at this machine
+	near           nearMisses
 }
 
 func (p *Player) PlayerUpdate() {
+	p.Impact = 0
+	p.Jumped = false
+	p.shield = max(0, p.shield-1)
 
 	if !p.dead {
 		if p.controls == Jumping {
-			p.Speed.Y += GRAVITY
+			p.Speed.Y += Tuning.Gravity
 		} else if p.controls == Flying {
 			p.Speed.Y -= GameSpeed
 		}
 
 		p.stuck = false
 
-		if ebiten.IsKeyPressed(ebiten.KeyRight) {
-			p.Speed.X += PLAYER_ACCEL
+		if p.Input.Pressed(ActionRight) {
+			p.Speed.X += Tuning.PlayerAccel
 			p.FacingRight = true
 		}
 
-		if ebiten.IsKeyPressed(ebiten.KeyLeft) {
-			p.Speed.X -= PLAYER_ACCEL
+		if p.Input.Pressed(ActionLeft) {
+			p.Speed.X -= Tuning.PlayerAccel
 			p.FacingRight = false
 		}
 
-		if ebiten.IsKeyPressed(ebiten.KeyUp) && p.controls == Flying {
+		if p.Input.Pressed(ActionUp) && p.controls == Flying {
 			if p.Object.Position.Y > (WORLD_HEIGTH-HALF_HEIGHT)+64 {
 				p.Object.Position.Y -= GameSpeed
 			}
 		}
 
-		if ebiten.IsKeyPressed(ebiten.KeyDown) && p.controls == Flying {
+		if p.Input.Pressed(ActionDown) && p.controls == Flying {
 			if p.Object.Bottom() < WORLD_HEIGTH+100 {
 				p.Object.Position.Y += GameSpeed
 			}
@@ -70,16 +156,17 @@ func (p *Player) PlayerUpdate() {
 		}
 
 		//Check for jumping
-		if inpututil.IsKeyJustPressed(ebiten.KeyZ) && p.controls == Jumping {
+		if p.Input.JustPressed(ActionJump) && p.controls == Jumping {
 
-			if ebiten.IsKeyPressed(ebiten.KeyDown) && p.OnGround != nil && p.OnGround.HasTags("platform") {
+			if p.Input.Pressed(ActionDown) && p.OnGround != nil && p.OnGround.HasTags("platform") {
 
 				p.IgnorePlatform = p.OnGround
 
 			} else {
 
 				if p.OnGround != nil {
-					p.Speed.Y = -JMP_SPEED
+					p.Speed.Y = -Tuning.JumpSpeed
+					p.Jumped = true
 				}
 
 			}
@@ -135,13 +222,18 @@ func (p *Player) PlayerUpdate() {
 						p.OnGround = platform
 						//p.Speed.Y = 0
 
-						p.dead = true
+						if !p.God && p.shield == 0 {
+							p.die(platform)
+						}
 					}
 				}
 
 				//Check solid ground
 				if solids := check.ObjectsByTags("solid"); len(solids) > 0 && (p.OnGround == nil || p.OnGround.Position.Y >= solids[0].Position.Y) {
 					dy = check.ContactWithObject(solids[0]).Y
+					if p.Speed.Y > Tuning.Gravity {
+						p.Impact = p.Speed.Y
+					}
 					p.Speed.Y = 0
 
 					if solids[0].Position.Y > p.Object.Position.Y {
@@ -179,43 +271,68 @@ func (p *Player) PlayerUpdate() {
 }
 
 func Clamp(speed *float64) {
-	if *speed > PLAYER_FRICTION {
-		*speed -= PLAYER_FRICTION
-	} else if *speed < -PLAYER_FRICTION {
-		*speed += PLAYER_FRICTION
+	if *speed > Tuning.PlayerFriction {
+		*speed -= Tuning.PlayerFriction
+	} else if *speed < -Tuning.PlayerFriction {
+		*speed += Tuning.PlayerFriction
 	} else {
 		*speed = 0
 	}
 
-	if *speed > MAX_SPEED {
-		*speed = MAX_SPEED
-	} else if *speed < -MAX_SPEED {
-		*speed = -MAX_SPEED
+	if *speed > Tuning.MaxSpeed {
+		*speed = Tuning.MaxSpeed
+	} else if *speed < -Tuning.MaxSpeed {
+		*speed = -Tuning.MaxSpeed
 	}
 }
 
-func NewPlayer(game *Game, pos Vec2) *Player {
+// NewPlayer adds the player to the space, slot keeps the sprites of several players apart
+func NewPlayer(game *Game, pos Vec2, slot int) *Player {
 
 	p := &Player{
 		Object:      rv.NewObject(pos[0], pos[1], 16, 16),
 		FacingRight: true,
 		controls:    Flying,
+		Input:       PlayerInput{Gamepad: -1},
+		events:      game.events,
 	}
 
 	p.Object.SetShape(rv.NewRectangle(0, 0, p.Object.Size.X, p.Object.Size.Y))
 	game.space.Add(p.Object)
 
-	grid := ganim8.NewGrid(16, 16, AtlasW, AtlasH, 192, 32)
-	anim := ganim8.New(Atlas, grid.Frames("1-3", 1), time.Millisecond*60)
+	anim := animationOrBlank("player")
 
 	p.Sprite = Sprite{
 		Object:    p.Object,
-		Layer:     BeforeTower,
 		Animation: anim,
 	}
 
-	game.sprites[99] = &p.Sprite
+	game.sprites[slot] = &p.Sprite
 
 	return p
 
 }
+
+func (p *Player) die(on *rv.Object) {
+	p.dead = true
+	e := PlayerDied{Player: p.Index, Local: p.Local, Pos: p.center()}
+	if platform, ok := on.Data.(*Platform); ok {
+		e.Platform = platform.kind
+	}
+	Publish(p.events, e)
+}
+
+func (p *Player) center() Vec2 {
+	obj := p.Object
+	return Vec2{obj.Position.X + obj.Size.X/2, obj.Position.Y + obj.Size.Y/2}
+}
+
+// state is the copy of the player handed to subscribers of Ticked
+func (p *Player) state() PlayerState {
+	return PlayerState{
+		Pos:      Vec2{p.Object.Position.X, p.Object.Position.Y},
+		OnGround: p.OnGround != nil,
+		Dead:     p.dead,
+		Left:     p.Input.Pressed(ActionLeft),
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// Renderer draws the game into a fixed size canvas and scales it to the window
+type Renderer struct {
+	PixelPerfect bool
+	canvas       *ebiten.Image
+	matrix       ebiten.GeoM
+}
+
+func NewRenderer() *Renderer {
+	return &Renderer{
+		PixelPerfect: true,
+		canvas:       ebiten.NewImage(SCREEN_WIDTH, SCREEN_HEIGHT),
+	}
+}
+
+// scale is a whole multiple of the canvas in pixel-perfect mode unless the window is smaller than the canvas
+func (r *Renderer) scale(w, h int) float64 {
+	s := math.Min(float64(w)/SCREEN_WIDTH, float64(h)/SCREEN_HEIGHT)
+	if r.PixelPerfect && s >= 1 {
+		s = math.Floor(s)
+	}
+	return s
+}
+
+// Present scales the canvas into the screen and letterboxes the rest
+func (r *Renderer) Present(screen *ebiten.Image) {
+	w, h := screen.Bounds().Dx(), screen.Bounds().Dy()
+	s := r.scale(w, h)
+
+	r.matrix.Reset()
+	r.matrix.Scale(s, s)
+	r.matrix.Translate(
+		math.Floor((float64(w)-SCREEN_WIDTH*s)/2),
+		math.Floor((float64(h)-SCREEN_HEIGHT*s)/2),
+	)
+
+	op := &ebiten.DrawImageOptions{GeoM: r.matrix}
+	if !r.PixelPerfect {
+		op.Filter = ebiten.FilterLinear
+	}
+
+	screen.Fill(color.Black)
+	screen.DrawImage(r.canvas, op)
+}
+
+// ScreenToCanvas maps a window position, like the cursor, onto the canvas
+func (r *Renderer) ScreenToCanvas(x, y int) (int, int) {
+	m := r.matrix
+	if !m.IsInvertible() {
+		return x, y
+	}
+	m.Invert()
+	cx, cy := m.Apply(float64(x), float64(y))
+	return int(cx), int(cy)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const SETTINGS_VERSION = 1
+
+const settingsFile = "settings.json"
+
+type Action string
+
+const (
+	ActionLeft    Action = "left"
+	ActionRight   Action = "right"
+	ActionUp      Action = "up"
+	ActionDown    Action = "down"
+	ActionJump    Action = "jump"
+	ActionPause   Action = "pause"
+	ActionRestart Action = "restart"
+	ActionMute    Action = "mute"
+)
+
+var Actions = []Action{ActionLeft, ActionRight, ActionUp, ActionDown, ActionJump, ActionPause, ActionRestart, ActionMute}
+
+type KeyBindings map[Action]ebiten.Key
+
+// Keys is the active binding table, the player and menus read input through it
+var Keys = DefaultKeys()
+
+func DefaultKeys() KeyBindings {
+	return KeyBindings{
+		ActionLeft:    ebiten.KeyLeft,
+		ActionRight:   ebiten.KeyRight,
+		ActionUp:      ebiten.KeyUp,
+		ActionDown:    ebiten.KeyDown,
+		ActionJump:    ebiten.KeyZ,
+		ActionPause:   ebiten.KeyP,
+		ActionRestart: ebiten.KeyR,
+		ActionMute:    ebiten.KeyM,
+	}
+}
+
+// Bind gives the key to the action, an action that had the key takes over the old key of this one
+func (kb KeyBindings) Bind(action Action, key ebiten.Key) {
+	for other, k := range kb {
+		if k == key && other != action {
+			kb[other] = kb[action]
+		}
+	}
+	kb[action] = key
+}
+
+// reservedKey tells if the game reads the key itself, or it belongs to players 2 to 4,
+// so no action can be bound to it
+func reservedKey(key ebiten.Key) bool {
+	switch key {
+	case ebiten.KeyEnter, ebiten.KeyEscape, ebiten.KeyGraveAccent,
+		ebiten.KeyQ, ebiten.KeyE, ebiten.KeyS, ebiten.KeyT, ebiten.KeyD,
+		ebiten.KeyF1, ebiten.KeyF2, ebiten.KeyF3:
+		return true
+	}
+	for _, keys := range extraKeys {
+		for _, k := range keys {
+			if k == key {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type Settings struct {
+	Version int `json:"version"`
+
+	Fullscreen   bool `json:"fullscreen"`
+	WindowScale  int  `json:"window_scale"`
+	Vsync        bool `json:"vsync"`
+	PixelPerfect bool `json:"pixel_perfect"`
+
+	MasterVolume float64 `json:"master_volume"`
+	MusicVolume  float64 `json:"music_volume"`
+	EffectVolume float64 `json:"effect_volume"`
+	Muted        bool    `json:"muted"`
+
+	Keys KeyBindings `json:"keys"`
+	Mode ControlMode `json:"mode"`
+
+	//accessibility
+	ScreenShake     float64 `json:"screen_shake"`
+	ReduceParticles bool    `json:"reduce_particles"`
+
+	Language string `json:"language"`
+
+	//local multiplayer
+	Players int       `json:"players"`
+	Party   PartyMode `json:"party"`
+
+	appliedScale int // window scale last set, a window resized by hand keeps its size otherwise
+}
+
+func DefaultSettings() *Settings {
+	return &Settings{
+		Version:      SETTINGS_VERSION,
+		WindowScale:  1,
+		Vsync:        true,
+		PixelPerfect: true,
+		MasterVolume: 1.0,
+		MusicVolume:  0.5,
+		EffectVolume: 0.8,
+		Keys:         DefaultKeys(),
+		Mode:         Flying,
+		ScreenShake:  1.0,
+		Language:     "en",
+		Players:      1,
+		Party:        Coop,
+	}
+}
+
+// LoadSettings falls back to the defaults when nothing was saved yet or the file is broken
+func LoadSettings() *Settings {
+	s := DefaultSettings()
+
+	data, err := loadData(settingsFile)
+	if err != nil || data == nil {
+		return s
+	}
+
+	if err := json.Unmarshal(data, s); err != nil {
+		log.Println("Settings are corrupted, using defaults:", err)
+		return DefaultSettings()
+	}
+
+	s.migrate()
+	return s
+}
+
+// migrate brings settings saved by older versions up to date,
+// fields added later keep their defaults since the file is decoded over them
+func (s *Settings) migrate() {
+	if s.Keys == nil {
+		s.Keys = KeyBindings{}
+	}
+	for action, key := range DefaultKeys() {
+		if k, ok := s.Keys[action]; !ok || reservedKey(k) {
+			s.Keys[action] = key
+		}
+	}
+	s.WindowScale = max(1, min(s.WindowScale, 4))
+	s.Players = max(1, min(s.Players, MAX_PLAYERS))
+	if s.Language == "" {
+		s.Language = "en"
+	}
+	s.Version = SETTINGS_VERSION
+}
+
+func (s *Settings) Save() {
+	data, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		log.Println("Cannot encode settings:", err)
+		return
+	}
+	if err := saveData(settingsFile, data); err != nil {
+		log.Println("Cannot save settings:", err)
+	}
+}
+
+// applyDisplay only needs ebiten, it runs before anything is loaded so the window opens at the right size
+func (s *Settings) applyDisplay() {
+	ebiten.SetFullscreen(s.Fullscreen)
+	if s.WindowScale != s.appliedScale {
+		ebiten.SetWindowSize(SCREEN_WIDTH*s.WindowScale, SCREEN_HEIGHT*s.WindowScale)
+		s.appliedScale = s.WindowScale
+	}
+	ebiten.SetVsyncEnabled(s.Vsync)
+}
+
+// Apply pushes the settings into ebiten, the audio manager and the globals read during play
+func (s *Settings) Apply(g *Game) {
+	s.applyDisplay()
+	g.renderer.PixelPerfect = s.PixelPerfect
+	for _, v := range g.views {
+		v.Camera.SetPixelPerfect(s.PixelPerfect)
+		v.Camera.ShakeScale = s.ScreenShake
+	}
+
+	g.audio.Master = s.MasterVolume
+	g.audio.Music = s.MusicVolume
+	g.audio.Effects = s.EffectVolume
+	g.audio.Muted = s.Muted
+	g.audio.applyVolume()
+
+	g.particles.Reduced = s.ReduceParticles
+
+	Keys = s.Keys
+
+	//after a failed load the setting follows the language kept, so it is not tried again on every Apply
+	if Lang.Code != s.Language {
+		if err := SetLanguage(s.Language); err != nil {
+			log.Println("Language failed:", err)
+			s.Language = Lang.Code
+		}
+	}
+}
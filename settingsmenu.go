@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+type SettingsMenu struct {
+	settings  *Settings
+	ui        *UIScreen
+	rebinding Action     // set while waiting for the new key of an action
+	refused   ebiten.Key // reserved key pressed while rebinding, -1 when there is none
+	closed    bool
+}
+
+func NewSettingsMenu(s *Settings) *SettingsMenu {
+	m := &SettingsMenu{settings: s, refused: -1}
+
+	onOff := func(b *bool) Text {
+		return func() string {
+			if *b {
+				return T("on")
+			}
+			return T("off")
+		}
+	}
+	percent := func(v float64) string {
+		return fmt.Sprintf("%d%%", int(math.Round(v*100)))
+	}
+	toggle := func(b *bool) func(int) {
+		return func(int) { *b = !*b }
+	}
+
+	items := []Widget{
+		NewOption(Msg("fullscreen"), onOff(&s.Fullscreen), toggle(&s.Fullscreen)),
+		NewOption(Msg("window_scale"), func() string { return fmt.Sprintf("x%d", s.WindowScale) }, func(dir int) {
+			s.WindowScale = max(1, min(s.WindowScale+dir, 4))
+		}),
+		NewOption(Msg("vsync"), onOff(&s.Vsync), toggle(&s.Vsync)),
+		NewOption(Msg("scaling"), func() string {
+			if s.PixelPerfect {
+				return T("pixel_perfect")
+			}
+			return T("fit_screen")
+		}, toggle(&s.PixelPerfect)),
+		NewSlider(Msg("master_volume"), &s.MasterVolume, 0.1, percent),
+		NewSlider(Msg("music_volume"), &s.MusicVolume, 0.1, percent),
+		NewSlider(Msg("effects_volume"), &s.EffectVolume, 0.1, percent),
+		NewOption(Msg("mute"), onOff(&s.Muted), toggle(&s.Muted)),
+		NewOption(Msg("game_mode"), func() string { return T("mode_" + s.Mode.String()) }, func(int) {
+			if s.Mode == Flying {
+				s.Mode = Jumping
+			} else {
+				s.Mode = Flying
+			}
+		}),
+		NewSlider(Msg("screen_shake"), &s.ScreenShake, 0.25, percent),
+		NewOption(Msg("reduce_particles"), onOff(&s.ReduceParticles), toggle(&s.ReduceParticles)),
+		NewOption(Msg("players"), func() string { return fmt.Sprintf("%d", s.Players) }, func(dir int) {
+			s.Players = (s.Players-1+dir+MAX_PLAYERS)%MAX_PLAYERS + 1
+		}),
+		NewOption(Msg("party"), func() string { return T("party_" + s.Party.String()) }, func(int) {
+			if s.Party == Coop {
+				s.Party = Versus
+			} else {
+				s.Party = Coop
+			}
+		}),
+		NewOption(Msg("language"), func() string { return LanguageName(s.Language) }, func(dir int) {
+			langs := Languages()
+			i := 0
+			for j, code := range langs {
+				if code == s.Language {
+					i = j
+				}
+			}
+			s.Language = langs[(i+dir+len(langs))%len(langs)]
+		}),
+	}
+
+	for _, a := range Actions {
+		key := &Button{
+			Text: func() string { return T("key_binding", "action", T("action_"+string(a))) },
+			Value: func() string {
+				if m.rebinding == a && m.refused >= 0 {
+					return T("key_reserved", "key", m.refused.String())
+				}
+				if m.rebinding == a {
+					return T("press_key")
+				}
+				return s.Keys[a].String()
+			},
+			OnPress: func() { m.rebinding = a },
+		}
+		items = append(items, key)
+	}
+
+	items = append(items, NewButton(Msg("back"), func() { m.closed = true }))
+
+	m.ui = NewUIScreen(NewPanel(AnchorTopLeft, image.Point{150, 20},
+		NewLabel(Msg("settings_title"), true),
+		NewList(18, items...),
+	))
+
+	return m
+}
+
+// Update returns true while the menu stays open, settings are saved and applied on close
+func (m *SettingsMenu) Update(g *Game) bool {
+	if m.rebinding != "" {
+		for k := ebiten.Key(0); k <= ebiten.KeyMax; k++ {
+			if !inpututil.IsKeyJustPressed(k) {
+				continue
+			}
+			//keep waiting on a reserved key, Escape gives up
+			if k != ebiten.KeyEscape && reservedKey(k) {
+				m.refused = k
+				break
+			}
+			if k != ebiten.KeyEscape {
+				m.settings.Keys.Bind(m.rebinding, k)
+			}
+			m.rebinding, m.refused = "", -1
+			g.audio.Play(SoundMenu)
+			break
+		}
+		return true
+	}
+
+	switch m.ui.Update() {
+	case UIMoved:
+		g.audio.Play(SoundMenu)
+	case UIChanged, UIActivated:
+		m.settings.Apply(g)
+		g.audio.Play(SoundMenu)
+	case UIBack:
+		m.closed = true
+	}
+
+	if m.closed {
+		m.settings.Apply(g)
+		m.settings.Save()
+		return false
+	}
+	return true
+}
+
+func (m *SettingsMenu) Draw(screen *ebiten.Image) {
+	m.ui.Draw(screen)
+}
@@ -1,7 +1,7 @@
 package main
 
 import (
-	"image/color"
+	"image"
 	"math"
 
 	"github.com/hajimehoshi/ebiten/v2"
@@ -10,60 +10,138 @@ import (
 )
 
 type Sprite struct {
+	Projection
 	Object    *rv.Object
-	Layer     LayerID
 	Animation *ganim8.Animation
-	DrawPos   Vec2
-	Drawable  bool
-	Behind    bool
+}
 
-	Color color.RGBA
+// Projection is where and how a world rectangle ends up on the tower cylinder
+type Projection struct {
+	Layer   LayerID
+	DrawPos Vec2
+	Scale   float64
+	Depth   float64
+	Clip    image.Rectangle
+	Behind  bool
 }
 
-func (s *Sprite) Update(g *Game) {
-	leftEdge, rightEdge := g.player.Object.Position.X-(96+s.Object.Size.X+10), g.player.Object.Position.X+(96+s.Object.Size.X+10)
-	s.DrawPos = Vec2{s.Object.Position.X, s.Object.Position.Y}
-	s.Color = color.RGBA{225, 30, 60, 225}
+// radius of the tower as seen on screen and the angle one world pixel covers on its circumference
+var (
+	towerRadius = TOWER_BOUNDS / (2 * math.Pi)
+	towerAngle  = 2 * math.Pi / TOWER_BOUNDS
+)
+
+// 2x2 bayer dither, pixels get discarded the deeper the sprite is behind the tower
+const ditherSrc = `//kage:unit pixels
+
+package main
+
+var Depth float
+
+func Fragment(dstPos vec4, srcPos vec2, color vec4) vec4 {
+	a := mod(floor(dstPos.x), 2)
+	b := mod(floor(dstPos.y), 2)
+	threshold := (mod(2*a+3*b, 4) + 0.5) / 4
+	if Depth > threshold {
+		return vec4(0)
+	}
+	return imageSrc0At(srcPos)
+}
+`
+
+var DitherShader *ebiten.Shader
+
+// project wraps the horizontal distance from the tower center around the cylinder
+// and returns the angle of the point, 0 is facing the camera
+func project(x, center float64) float64 {
+	return math.Remainder(x-center, TOWER_BOUNDS) * towerAngle
+}
 
+func (s *Sprite) Update(g *Game) {
 	if s.Animation != nil {
 		s.Animation.Update()
 	}
 
-	s.Layer = BeforeTower
+	s.Project(g.player.Object.Position.X)
+}
 
-	if s.Object.Position.X <= leftEdge {
-		s.Layer = BehindTower
-		s.Behind = true
-		s.Color = color.RGBA{30, 225, 60, 225}
+// Project places the sprite for a view centered on the given x, split screen calls it once per view
+func (s *Sprite) Project(center float64) {
+	s.Projection = projectRect(s.Object.Position.X, s.Object.Position.Y, s.Object.Size.X, s.Object.Size.Y, center)
+}
 
-		offset := math.Abs(s.Object.Position.X - leftEdge)
-		s.DrawPos = Vec2{leftEdge + offset, s.Object.Position.Y}
+func projectRect(x, y, w, h, center float64) Projection {
+	left := project(x, center)
+	right := left + w*towerAngle
 
-		if s.Object.Position.X <= leftEdge-TOWER_WIDTH {
-			s.Layer = Invisible
-			s.Color = color.RGBA{30, 30, 225, 225}
-		}
+	pr := Projection{
+		Layer:   BeforeTower,
+		DrawPos: Vec2{center + towerRadius*math.Sin(left), y},
+		Scale:   1,
 	}
 
-	if s.Object.Right() >= rightEdge {
-		s.Layer = BehindTower
-		s.Behind = true
-		s.Color = color.RGBA{30, 225, 60, 225}
-
-		offset := math.Abs(s.Object.Right() - rightEdge)
-		s.DrawPos = Vec2{(rightEdge - offset) - s.Object.Size.X, s.Object.Position.Y}
-
-		if s.Object.Right() >= rightEdge+TOWER_WIDTH {
-			s.Layer = Invisible
-			s.Color = color.RGBA{30, 30, 225, 225}
+	switch front := math.Pi / 2; {
+	case left >= -front && right <= front:
+		pr.Scale = towerRadius * (math.Sin(right) - math.Sin(left)) / w
+
+	case left < front && right > front:
+		//straddles the right silhouette, draw the front part up to the edge of the tower
+		visible := (front - left) / (right - left)
+		edge := center + towerRadius
+		pr.Scale = (edge - pr.DrawPos[0]) / (visible * w)
+		pr.Clip = image.Rect(int(pr.DrawPos[0]), int(y), int(math.Ceil(edge)), int(math.Ceil(y+h)))
+
+	case left < -front && right > -front:
+		//straddles the left silhouette
+		visible := (right + front) / (right - left)
+		edge := center - towerRadius
+		rightX := center + towerRadius*math.Sin(right)
+		pr.Scale = (rightX - edge) / (visible * w)
+		pr.DrawPos[0] = rightX - pr.Scale*w
+		pr.Clip = image.Rect(int(edge), int(y), int(math.Ceil(rightX)), int(math.Ceil(y+h)))
+
+	default:
+		//behind the tower, only the part sticking out of the silhouette can be seen
+		pr.Behind = true
+		pr.Layer = BehindTower
+
+		outer := towerRadius + TILE_SIZE
+		x1, x2 := center+outer*math.Sin(left), center+outer*math.Sin(right)
+		pr.DrawPos[0] = math.Min(x1, x2)
+		pr.Scale = math.Abs(x2-x1) / w
+		pr.Depth = -math.Cos((left + right) / 2)
+
+		if math.Max(x1, x2) < center+TOWER_WIDTH/2 && pr.DrawPos[0] > center-TOWER_WIDTH/2 {
+			pr.Layer = Invisible
 		}
 	}
 
+	return pr
+}
+
+// target returns the image to draw into, clipped to the silhouette if needed
+func (pr *Projection) target(screen *ebiten.Image) *ebiten.Image {
+	if pr.Clip.Empty() {
+		return screen
+	}
+	return screen.SubImage(pr.Clip).(*ebiten.Image)
 }
 
 func (s *Sprite) Draw(screen *ebiten.Image) {
-	if s.Animation != nil {
+	if s.Animation == nil || s.Scale <= 0 {
+		return
+	}
+
+	dst := s.target(screen)
+	opts := ganim8.DrawOpts(s.DrawPos[0], s.DrawPos[1], 0, s.Scale, 1)
 
-		s.Animation.Draw(screen, ganim8.DrawOpts(s.DrawPos[0], s.DrawPos[1]))
+	if s.Behind && DitherShader != nil {
+		s.Animation.DrawWithShader(dst, opts, &ganim8.ShaderOptions{
+			Shader:   DitherShader,
+			Uniforms: map[string]interface{}{"Depth": float32(s.Depth)},
+		})
+		return
 	}
+
+	s.Animation.Draw(dst, opts)
 }
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const (
+	statsFile    = "stats.json"
+	STATS_RECENT = 100 // runs kept for the per run analytics
+)
+
+// RunStats is one finished run, durations are in seconds and distances in world pixels
+type RunStats struct {
+	Date       string  `json:"date"`
+	Mode       string  `json:"mode"`
+	Seed       int64   `json:"seed"`
+	Players    int     `json:"players"`
+	Score      int     `json:"score"`
+	Difficulty int     `json:"difficulty"`
+	Seconds    float64 `json:"seconds"`
+	Falling    float64 `json:"falling"`  // time without ground under the player
+	Sideways   float64 `json:"sideways"` // moved left and right together
+	Behind     float64 `json:"behind"`   // time on the far side of the tower from where the run started
+	DeathBy    string  `json:"death_by,omitempty"`
+}
+
+// Stats are the lifetime totals of the local player, cheated runs are left out
+type Stats struct {
+	Runs           int            `json:"runs"`
+	Seconds        float64        `json:"seconds"`
+	Falling        float64        `json:"falling"`
+	Sideways       float64        `json:"sideways"`
+	Behind         float64        `json:"behind"`
+	BestScore      int            `json:"best_score"`
+	BestDifficulty int            `json:"best_difficulty"`
+	DeathsBy       map[string]int `json:"deaths_by"`
+	Recent         []RunStats     `json:"recent"`
+
+	run     RunStats
+	running bool
+	cheated bool
+	startX  float64
+	lastX   float64
+}
+
+func LoadStats() *Stats {
+	s := &Stats{}
+	if b, err := loadData(statsFile); err == nil && b != nil {
+		if err := json.Unmarshal(b, s); err != nil {
+			log.Println("Stats are corrupted:", err)
+			s = &Stats{}
+		}
+	}
+	if s.DeathsBy == nil {
+		s.DeathsBy = map[string]int{}
+	}
+	return s
+}
+
+func (s *Stats) Save() {
+	b, err := json.MarshalIndent(s, "", "\t")
+	if err == nil {
+		err = saveData(statsFile, b)
+	}
+	if err != nil {
+		log.Println("Cannot save stats:", err)
+	}
+}
+
+// Export writes a dated copy for the playtest sheets and returns where it went,
+// the full path on desktop and the downloaded name in the browser
+func (s *Stats) Export() (string, error) {
+	name := "stats-" + time.Now().Format("2006-01-02-150405") + ".json"
+	b, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return "", err
+	}
+	return exportData(name, b)
+}
+
+func (s *Stats) Listen(bus *Bus) {
+	Subscribe(bus, s.tick)
+	Subscribe(bus, func(Cheated) { s.cheated = true })
+	Subscribe(bus, func(e RunStarted) {
+		s.run = RunStats{
+			Mode:    e.Mode.String(),
+			Seed:    e.Seed,
+			Players: e.Players,
+		}
+		s.startX, s.lastX = e.Start[0], e.Start[0]
+		s.running = true
+	})
+	Subscribe(bus, func(e PlayerDied) {
+		if e.Local {
+			s.run.DeathBy = e.Platform.String()
+		}
+	})
+	Subscribe(bus, func(e RunEnded) {
+		if !s.running || s.cheated {
+			return
+		}
+		s.running = false
+		s.run.Date = time.Now().UTC().Format(time.DateOnly)
+		s.run.Score = e.Score
+		s.run.Difficulty = e.Difficulty
+		s.add(s.run)
+		s.Save()
+	})
+}
+
+func (s *Stats) add(r RunStats) {
+	s.Runs++
+	s.Seconds += r.Seconds
+	s.Falling += r.Falling
+	s.Sideways += r.Sideways
+	s.Behind += r.Behind
+	s.BestScore = max(s.BestScore, r.Score)
+	s.BestDifficulty = max(s.BestDifficulty, r.Difficulty)
+	if r.DeathBy != "" {
+		s.DeathsBy[r.DeathBy]++
+	}
+
+	s.Recent = append(s.Recent, r)
+	if len(s.Recent) > STATS_RECENT {
+		s.Recent = s.Recent[len(s.Recent)-STATS_RECENT:]
+	}
+}
+
+// tick measures the local player for one tick of a run
+func (s *Stats) tick(e Ticked) {
+	if !s.running || !e.Playing || e.Local.Dead {
+		return
+	}
+	const tick = 1.0 / 60
+
+	x := e.Local.Pos[0]
+	s.run.Seconds += tick
+	s.run.Sideways += math.Abs(x - s.lastX)
+	s.lastX = x
+	if !e.Local.OnGround {
+		s.run.Falling += tick
+	}
+	if math.Abs(project(x, s.startX)) > math.Pi/2 {
+		s.run.Behind += tick
+	}
+}
+
+func formatSeconds(sec float64) string {
+	return (time.Duration(sec) * time.Second).String()
+}
+
+// StatsScreen lists the lifetime stats and the recent runs, opened from the title
+type StatsScreen struct {
+	ui     *UIScreen
+	status string
+	closed bool
+}
+
+func NewStatsScreen(s *Stats) *StatsScreen {
+	m := &StatsScreen{}
+
+	line := func(id string, value func() string) Widget {
+		return NewOption(Msg(id), value, nil)
+	}
+	items := []Widget{
+		line("stats_runs", func() string { return Lang.FormatNumber(s.Runs) }),
+		line("stats_time", func() string { return formatSeconds(s.Seconds) }),
+		line("stats_falling", func() string { return formatSeconds(s.Falling) }),
+		line("stats_behind", func() string { return formatSeconds(s.Behind) }),
+		line("stats_sideways", func() string { return Lang.FormatNumber(int(s.Sideways)) }),
+		line("stats_best_score", func() string { return Lang.FormatNumber(s.BestScore) }),
+		line("stats_best_difficulty", func() string { return Lang.FormatNumber(s.BestDifficulty) }),
+		line("stats_deaths_by", func() string {
+			var by []string
+			for _, t := range PlatformTypes {
+				by = append(by, fmt.Sprintf("%s %d", t, s.DeathsBy[t.String()]))
+			}
+			return strings.Join(by, ", ")
+		}),
+		NewButton(Msg("stats_export"), func() {
+			name, err := s.Export()
+			if err != nil {
+				m.status = err.Error()
+				return
+			}
+			m.status = T("stats_exported", "file", name)
+		}),
+		NewButton(Msg("back"), func() { m.closed = true }),
+	}
+
+	// newest run first
+	for i := len(s.Recent) - 1; i >= 0; i-- {
+		r := s.Recent[i]
+		items = append(items, NewOption(
+			Static(fmt.Sprintf("%s %s", r.Date, T("mode_"+r.Mode))),
+			Static(fmt.Sprintf("%s  %s %d  %s", Lang.FormatNumber(r.Score), T("stats_level"), r.Difficulty, formatSeconds(r.Seconds))),
+			nil,
+		))
+	}
+
+	status := NewPanel(AnchorBottom, image.Point{0, -16}, NewLabel(func() string { return m.status }, false))
+	status.Visible = func() bool { return m.status != "" }
+
+	m.ui = NewUIScreen(
+		NewPanel(AnchorTopLeft, image.Point{150, 20},
+			NewLabel(Msg("stats_title"), true),
+			NewList(18, items...),
+		),
+		status,
+	)
+	return m
+}
+
+// Update returns true while the screen stays open
+func (m *StatsScreen) Update(g *Game) bool {
+	switch m.ui.Update() {
+	case UIMoved, UIActivated:
+		g.audio.Play(SoundMenu)
+	case UIBack:
+		m.closed = true
+	}
+	return !m.closed
+}
+
+func (m *StatsScreen) Draw(screen *ebiten.Image) {
+	m.ui.Draw(screen)
+}
@@ -0,0 +1,54 @@
+//go:build !js
+
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// dataPath puts every saved file in one folder of the user config dir
+func dataPath(name string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "hextower", name), nil
+}
+
+// loadData returns nil without an error when nothing was saved yet
+func loadData(name string) ([]byte, error) {
+	path, err := dataPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func saveData(name string, data []byte) error {
+	path, err := dataPath(name)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// exportData saves a file the player takes elsewhere and returns the full path to find it
+func exportData(name string, data []byte) (string, error) {
+	path, err := dataPath(name)
+	if err != nil {
+		return "", err
+	}
+	return path, saveData(name, data)
+}
@@ -0,0 +1,67 @@
+//go:build js
+
+package main
+
+import (
+	"errors"
+	"strings"
+	"syscall/js"
+)
+
+// storageKey keeps the key settings were saved under before other files were stored
+func storageKey(name string) string {
+	return "hextower." + strings.TrimSuffix(name, ".json")
+}
+
+func localStorage() (js.Value, error) {
+	storage := js.Global().Get("localStorage")
+	if storage.IsUndefined() || storage.IsNull() {
+		return js.Value{}, errors.New("localStorage is not available")
+	}
+	return storage, nil
+}
+
+func loadData(name string) ([]byte, error) {
+	storage, err := localStorage()
+	if err != nil {
+		return nil, err
+	}
+
+	item := storage.Call("getItem", storageKey(name))
+	if item.IsNull() {
+		return nil, nil
+	}
+	return []byte(item.String()), nil
+}
+
+func saveData(name string, data []byte) error {
+	storage, err := localStorage()
+	if err != nil {
+		return err
+	}
+
+	storage.Call("setItem", storageKey(name), string(data))
+	return nil
+}
+
+// exportData hands the file to the browser as a download, localStorage cannot be reached from outside
+func exportData(name string, data []byte) (string, error) {
+	doc := js.Global().Get("document")
+	if doc.IsUndefined() {
+		return "", errors.New("downloads are not available")
+	}
+
+	bytes := js.Global().Get("Uint8Array").New(len(data))
+	js.CopyBytesToJS(bytes, data)
+	blob := js.Global().Get("Blob").New([]any{bytes}, map[string]any{"type": "application/json"})
+	url := js.Global().Get("URL").Call("createObjectURL", blob)
+	defer js.Global().Get("URL").Call("revokeObjectURL", url)
+
+	a := doc.Call("createElement", "a")
+	a.Set("href", url)
+	a.Set("download", name)
+	doc.Get("body").Call("appendChild", a)
+	a.Call("click")
+	a.Call("remove")
+	return name, nil
+}
@@ -0,0 +1,140 @@
+// Package synth generates 1-bit style sound effects as PCM for ebiten audio streams.
+package synth
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+)
+
+type Wave string
+
+const (
+	Square Wave = "square"
+	Pulse  Wave = "pulse"
+	Noise  Wave = "noise"
+)
+
+// Envelope times are in seconds, Sustain is a level between 0 and 1
+type Envelope struct {
+	Attack  float64 `json:"attack"`
+	Decay   float64 `json:"decay"`
+	Sustain float64 `json:"sustain"`
+	Release float64 `json:"release"`
+}
+
+// Preset describes one sound effect
+type Preset struct {
+	Name      string    `json:"name"`
+	Wave      Wave      `json:"wave"`
+	Duty      float64   `json:"duty"`      // pulse width, only used by the pulse wave
+	Frequency float64   `json:"frequency"` // Hz at the start of the note
+	SlideTo   float64   `json:"slide_to"`  // Hz at the end of the note, 0 keeps the pitch
+	Arpeggio  []float64 `json:"arpeggio"`  // semitone offsets cycled through
+	ArpRate   float64   `json:"arp_rate"`  // seconds per arpeggio step
+	Duration  float64   `json:"duration"`  // seconds before the release starts
+	Volume    float64   `json:"volume"`
+	Envelope  Envelope  `json:"envelope"`
+}
+
+// LoadPresets reads a JSON list of presets and indexes them by name
+func LoadPresets(data []byte) (map[string]Preset, error) {
+	var list []Preset
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+
+	presets := make(map[string]Preset, len(list))
+	for _, p := range list {
+		presets[p.Name] = p
+	}
+	return presets, nil
+}
+
+// Length is the number of samples the preset renders to including the release
+func (p Preset) Length(sampleRate int) int {
+	return int((p.Duration + p.Envelope.Release) * float64(sampleRate))
+}
+
+func (p Preset) frequency(t float64) float64 {
+	f := p.Frequency
+	if p.SlideTo > 0 && p.Duration > 0 {
+		f *= math.Pow(p.SlideTo/p.Frequency, math.Min(t/p.Duration, 1))
+	}
+
+	if len(p.Arpeggio) > 0 && p.ArpRate > 0 {
+		step := int(t/p.ArpRate) % len(p.Arpeggio)
+		f *= math.Pow(2, p.Arpeggio[step]/12)
+	}
+	return f
+}
+
+func (p Preset) amplitude(t float64) float64 {
+	e := p.Envelope
+
+	switch {
+	case t < e.Attack:
+		return t / e.Attack
+	case t < e.Attack+e.Decay:
+		return 1 - (1-e.Sustain)*(t-e.Attack)/e.Decay
+	case t < p.Duration:
+		return e.Sustain
+	case e.Release > 0:
+		return math.Max(e.Sustain*(1-(t-p.Duration)/e.Release), 0)
+	default:
+		return 0
+	}
+}
+
+// Samples renders the preset to mono samples between -1 and 1
+func (p Preset) Samples(sampleRate int) []float64 {
+	out := make([]float64, p.Length(sampleRate))
+
+	duty := 0.5
+	if p.Wave == Pulse && p.Duty > 0 {
+		duty = p.Duty
+	}
+
+	var phase float64
+	lfsr := uint16(1)
+	for i := range out {
+		t := float64(i) / float64(sampleRate)
+
+		phase += p.frequency(t) / float64(sampleRate)
+		var v float64
+		if p.Wave == Noise {
+			//15 bit shift register clocked once per period, like the old sound chips
+			for phase >= 1 {
+				phase--
+				bit := (lfsr ^ lfsr>>1) & 1
+				lfsr = lfsr>>1 | bit<<14
+			}
+			v = float64(lfsr&1)*2 - 1
+		} else {
+			phase -= math.Floor(phase)
+			v = 1
+			if phase >= duty {
+				v = -1
+			}
+		}
+
+		out[i] = v * p.amplitude(t) * p.Volume
+	}
+	return out
+}
+
+// PCM converts mono samples to 16 bit little endian stereo, the format ebiten audio plays
+func PCM(samples []float64) []byte {
+	b := make([]byte, len(samples)*4)
+	for i, s := range samples {
+		v := uint16(int16(math.Max(-1, math.Min(s, 1)) * math.MaxInt16))
+		binary.LittleEndian.PutUint16(b[i*4:], v)
+		binary.LittleEndian.PutUint16(b[i*4+2:], v)
+	}
+	return b
+}
+
+// Render is Samples followed by PCM
+func (p Preset) Render(sampleRate int) []byte {
+	return PCM(p.Samples(sampleRate))
+}
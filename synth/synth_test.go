@@ -0,0 +1,118 @@
+package synth
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// flat plays at full volume from the first sample, so only the wave decides the sign
+var flat = Envelope{Sustain: 1}
+
+func TestLength(t *testing.T) {
+	for _, tt := range []struct {
+		duration, release float64
+		rate, want        int
+	}{
+		{0.1, 0, 44100, 4410},
+		{0.1, 0.05, 44100, 6615},
+		{0.25, 0.25, 1000, 500},
+		{0, 0, 44100, 0},
+	} {
+		p := Preset{Wave: Square, Frequency: 440, Duration: tt.duration, Volume: 1, Envelope: Envelope{Sustain: 1, Release: tt.release}}
+		if n := p.Length(tt.rate); n != tt.want {
+			t.Errorf("Length(%v+%v at %d) = %d, want %d", tt.duration, tt.release, tt.rate, n, tt.want)
+		}
+		if n := len(p.Samples(tt.rate)); n != tt.want {
+			t.Errorf("len(Samples(%v+%v at %d)) = %d, want %d", tt.duration, tt.release, tt.rate, n, tt.want)
+		}
+	}
+}
+
+func TestDuty(t *testing.T) {
+	//16 Hz at 1024 samples per second is a period of 64 samples and a phase step of exactly 1/64
+	const rate, period = 1024, 64
+	for _, tt := range []struct {
+		wave Wave
+		duty float64
+		high int // samples of the period above zero
+	}{
+		{Square, 0, 32},
+		{Square, 0.25, 32}, // the square wave ignores the duty
+		{Pulse, 0, 32},
+		{Pulse, 0.25, 16},
+		{Pulse, 0.125, 8},
+		{Pulse, 0.75, 48},
+	} {
+		p := Preset{Wave: tt.wave, Duty: tt.duty, Frequency: 16, Duration: 1, Volume: 1, Envelope: flat}
+		samples := p.Samples(rate)
+		for i := range 2 * period {
+			//the phase advances before the first sample is taken
+			want := 1.0
+			if (i+1)%period >= tt.high {
+				want = -1
+			}
+			if samples[i] != want {
+				t.Errorf("%s duty %v: sample %d = %v, want %v", tt.wave, tt.duty, i, samples[i], want)
+				break
+			}
+		}
+	}
+}
+
+func TestEnvelope(t *testing.T) {
+	p := Preset{
+		Duration: 0.4,
+		Envelope: Envelope{Attack: 0.1, Decay: 0.1, Sustain: 0.5, Release: 0.2},
+	}
+	for _, tt := range []struct {
+		name string
+		t    float64
+		want float64
+	}{
+		{"start", 0, 0},
+		{"attack", 0.05, 0.5},
+		{"peak", 0.1, 1},
+		{"decay", 0.15, 0.75},
+		{"sustain", 0.2, 0.5},
+		{"held", 0.39, 0.5},
+		{"release", 0.5, 0.25},
+		{"silent", 0.6, 0},
+		{"after", 1, 0},
+	} {
+		if a := p.amplitude(tt.t); math.Abs(a-tt.want) > 1e-9 {
+			t.Errorf("%s: amplitude(%v) = %v, want %v", tt.name, tt.t, a, tt.want)
+		}
+	}
+}
+
+func TestNoise(t *testing.T) {
+	p := Preset{Wave: Noise, Frequency: 2000, Duration: 0.5, Volume: 1, Envelope: flat}
+	seen := map[float64]int{}
+	for i, v := range p.Samples(44100) {
+		if v != 1 && v != -1 {
+			t.Fatalf("sample %d = %v, want -1 or 1", i, v)
+		}
+		seen[v]++
+	}
+	if seen[1] == 0 || seen[-1] == 0 {
+		t.Errorf("noise never changes sign: %v", seen)
+	}
+}
+
+func TestPCM(t *testing.T) {
+	samples := []float64{0, 1, -1, 2, -2, 0.5}
+	want := []int16{0, math.MaxInt16, -math.MaxInt16, math.MaxInt16, -math.MaxInt16, math.MaxInt16 / 2}
+
+	b := PCM(samples)
+	if len(b) != len(samples)*4 {
+		t.Fatalf("len(PCM) = %d, want %d", len(b), len(samples)*4)
+	}
+	for i, w := range want {
+		left := int16(binary.LittleEndian.Uint16(b[i*4:]))
+		right := int16(binary.LittleEndian.Uint16(b[i*4+2:]))
+		if left != w || right != w {
+			t.Errorf("sample %v: channels %d and %d, want %d", samples[i], left, right, w)
+		}
+	}
+}
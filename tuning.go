@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+)
+
+// TuningValues are the gameplay numbers read from assets/tuning.json
+type TuningValues struct {
+	PlayerFriction  float64 `json:"player_friction"`
+	PlayerAccel     float64 `json:"player_accel"`
+	MaxSpeed        float64 `json:"max_speed"`
+	JumpSpeed       float64 `json:"jump_speed"`
+	Gravity         float64 `json:"gravity"`
+	StartSpeed      float64 `json:"start_speed"`
+	SpeedStep       float64 `json:"speed_step"`
+	DifficultyEvery int     `json:"difficulty_every"`
+	WaveSize        int     `json:"wave_size"`
+	ChunkChance     float64 `json:"chunk_chance"`     // share of waves taken from assets/chunks instead of generated
+	NearMissMargin  float64 `json:"near_miss_margin"` // pixels between player and platform that still count as a near miss
+	NearMissBonus   float64 `json:"near_miss_bonus"`  // score for a near miss, multiplied by the combo
+	ComboWindow     float64 `json:"combo_window"`     // seconds without a near miss before the combo resets
+	ComboMax        int     `json:"combo_max"`
+}
+
+var Tuning = DefaultTuning()
+
+func DefaultTuning() TuningValues {
+	return TuningValues{
+		PlayerFriction:  0.5,
+		PlayerAccel:     1.0,
+		MaxSpeed:        2.0,
+		JumpSpeed:       10.0,
+		Gravity:         0.75,
+		StartSpeed:      2.0,
+		SpeedStep:       0.3,
+		DifficultyEvery: 20,
+		WaveSize:        15,
+		ChunkChance:     0.25,
+		NearMissMargin:  6,
+		NearMissBonus:   2,
+		ComboWindow:     2,
+		ComboMax:        5,
+	}
+}
+
+// loadTuning keeps the defaults for values missing from the file
+func loadTuning() error {
+	b, err := fs.ReadFile(Assets, "assets/tuning.json")
+	if err != nil {
+		return err
+	}
+
+	t := DefaultTuning()
+	if err := json.Unmarshal(b, &t); err != nil {
+		return err
+	}
+	if t.DifficultyEvery <= 0 {
+		return errors.New("difficulty_every must be positive")
+	}
+
+	Tuning = t
+	return nil
+}
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"golang.org/x/image/font"
+)
+
+// Text is read every frame so labels follow the language and the values they show
+type Text func() string
+
+func Static(s string) Text {
+	return func() string { return s }
+}
+
+// Msg translates a message id with T
+func Msg(id string, args ...string) Text {
+	return func() string { return T(id, args...) }
+}
+
+type Anchor int
+
+const (
+	AnchorTopLeft Anchor = iota
+	AnchorTop
+	AnchorTopRight
+	AnchorLeft
+	AnchorCenter
+	AnchorRight
+	AnchorBottomLeft
+	AnchorBottom
+	AnchorBottomRight
+)
+
+// place returns the top left corner of a box anchored inside bounds, offset moves it from there
+func (a Anchor) place(bounds image.Rectangle, size, offset image.Point) image.Point {
+	col, row := int(a)%3, int(a)/3
+	return image.Point{
+		bounds.Min.X + (bounds.Dx()-size.X)*col/2 + offset.X,
+		bounds.Min.Y + (bounds.Dy()-size.Y)*row/2 + offset.Y,
+	}
+}
+
+type Widget interface {
+	Size() image.Point
+	Draw(screen *ebiten.Image, at image.Point, focus Control)
+}
+
+// Control is a widget the focus can land on
+type Control interface {
+	Widget
+	Activate()
+	Change(dir int)
+}
+
+// container is walked to find the controls in focus order
+type container interface {
+	children() []Widget
+}
+
+const (
+	UI_VALUE_COLUMN = 220 // where the values of buttons and sliders start
+	UI_SHADOW       = 1
+)
+
+func uiFace(big bool) font.Face {
+	if big {
+		return FontBig
+	}
+	return Font
+}
+
+func lineHeight(face font.Face) int {
+	m := face.Metrics()
+	return (m.Ascent + m.Descent).Ceil()
+}
+
+// drawString draws with a dark shadow so text stays readable over the tower
+func drawString(screen *ebiten.Image, s string, face font.Face, at image.Point, clr color.Color) {
+	y := at.Y + face.Metrics().Ascent.Ceil()
+	text.Draw(screen, s, face, at.X+UI_SHADOW, y+UI_SHADOW, color.Black)
+	text.Draw(screen, s, face, at.X, y, clr)
+}
+
+type Label struct {
+	Text  Text
+	Big   bool
+	Color color.Color
+}
+
+func NewLabel(txt Text, big bool) *Label {
+	return &Label{Text: txt, Big: big, Color: color.White}
+}
+
+func (l *Label) Size() image.Point {
+	face := uiFace(l.Big)
+	return image.Point{font.MeasureString(face, l.Text()).Ceil() + UI_SHADOW, lineHeight(face)}
+}
+
+func (l *Label) Draw(screen *ebiten.Image, at image.Point, _ Control) {
+	drawString(screen, l.Text(), uiFace(l.Big), at, l.Color)
+}
+
+// Button shows an optional value next to its text, left and right change it, accept presses it
+type Button struct {
+	Text     Text
+	Value    Text
+	OnPress  func()
+	OnChange func(dir int)
+}
+
+func NewButton(txt Text, onPress func()) *Button {
+	return &Button{Text: txt, OnPress: onPress}
+}
+
+// NewOption is a button cycling a value, pressing it steps forward
+func NewOption(txt, value Text, onChange func(dir int)) *Button {
+	return &Button{Text: txt, Value: value, OnChange: onChange}
+}
+
+func (b *Button) Size() image.Point {
+	w := font.MeasureString(Font, "> "+b.Text()).Ceil()
+	if b.Value != nil {
+		w = max(w, UI_VALUE_COLUMN) + font.MeasureString(Font, b.Value()).Ceil()
+	}
+	return image.Point{w + UI_SHADOW, lineHeight(Font)}
+}
+
+func (b *Button) Draw(screen *ebiten.Image, at image.Point, focus Control) {
+	prefix := "  "
+	if focus == Control(b) {
+		prefix = "> "
+	}
+	drawString(screen, prefix+b.Text(), Font, at, color.White)
+	if b.Value != nil {
+		drawString(screen, b.Value(), Font, at.Add(image.Point{UI_VALUE_COLUMN, 0}), color.White)
+	}
+}
+
+func (b *Button) Activate() {
+	switch {
+	case b.OnPress != nil:
+		b.OnPress()
+	case b.OnChange != nil:
+		b.OnChange(1)
+	}
+}
+
+func (b *Button) Change(dir int) {
+	if b.OnChange != nil {
+		b.OnChange(dir)
+	}
+}
+
+// Slider edits a value between 0 and 1
+type Slider struct {
+	Text     Text
+	Value    *float64
+	Step     float64
+	Format   func(v float64) string
+	OnChange func()
+}
+
+const SLIDER_WIDTH = 80
+
+func NewSlider(txt Text, value *float64, step float64, format func(float64) string) *Slider {
+	return &Slider{Text: txt, Value: value, Step: step, Format: format}
+}
+
+func (s *Slider) Size() image.Point {
+	w := UI_VALUE_COLUMN + SLIDER_WIDTH + 8 + font.MeasureString(Font, s.Format(*s.Value)).Ceil()
+	return image.Point{w, lineHeight(Font)}
+}
+
+func (s *Slider) Draw(screen *ebiten.Image, at image.Point, focus Control) {
+	prefix := "  "
+	if focus == Control(s) {
+		prefix = "> "
+	}
+	drawString(screen, prefix+s.Text(), Font, at, color.White)
+
+	h := float32(lineHeight(Font))
+	x, y := float32(at.X+UI_VALUE_COLUMN), float32(at.Y)+h/2-3
+	vector.StrokeRect(screen, x, y, SLIDER_WIDTH, 6, 1, color.White, false)
+	vector.DrawFilledRect(screen, x, y, float32(*s.Value)*SLIDER_WIDTH, 6, color.White, false)
+	drawString(screen, s.Format(*s.Value), Font, at.Add(image.Point{UI_VALUE_COLUMN + SLIDER_WIDTH + 8, 0}), color.White)
+}
+
+func (s *Slider) Activate() {}
+
+func (s *Slider) Change(dir int) {
+	*s.Value = max(0, min(*s.Value+float64(dir)*s.Step, 1))
+	if s.OnChange != nil {
+		s.OnChange()
+	}
+}
+
+// List stacks its items and scrolls so the focused one stays in the visible rows
+type List struct {
+	Items   []Widget
+	Rows    int // 0 shows everything
+	Spacing int
+	scroll  int
+}
+
+func NewList(rows int, items ...Widget) *List {
+	return &List{Items: items, Rows: rows, Spacing: 2}
+}
+
+func (l *List) children() []Widget {
+	return l.Items
+}
+
+func (l *List) visible() []Widget {
+	if l.Rows <= 0 || len(l.Items) <= l.Rows {
+		return l.Items
+	}
+	return l.Items[l.scroll : l.scroll+l.Rows]
+}
+
+func (l *List) Size() image.Point {
+	var size image.Point
+	for _, w := range l.visible() {
+		s := w.Size()
+		size.X = max(size.X, s.X)
+		size.Y += s.Y + l.Spacing
+	}
+	return size
+}
+
+func (l *List) Draw(screen *ebiten.Image, at image.Point, focus Control) {
+	if l.Rows > 0 && len(l.Items) > l.Rows {
+		for i, w := range l.Items {
+			if Widget(focus) == w {
+				l.scroll = max(min(l.scroll, i), i-l.Rows+1)
+			}
+		}
+	}
+
+	for _, w := range l.visible() {
+		w.Draw(screen, at, focus)
+		at.Y += w.Size().Y + l.Spacing
+	}
+}
+
+// Panel is a vertical stack anchored to the screen, the top level of a UI
+type Panel struct {
+	Anchor     Anchor
+	Offset     image.Point
+	Padding    int
+	Spacing    int
+	Background color.Color // nil draws no box
+	Children   []Widget
+	Visible    func() bool // nil is always visible
+}
+
+func NewPanel(anchor Anchor, offset image.Point, children ...Widget) *Panel {
+	return &Panel{Anchor: anchor, Offset: offset, Padding: 4, Spacing: 4, Children: children}
+}
+
+func (p *Panel) children() []Widget {
+	return p.Children
+}
+
+func (p *Panel) shown() bool {
+	return p.Visible == nil || p.Visible()
+}
+
+func (p *Panel) Size() image.Point {
+	var size image.Point
+	for i, w := range p.Children {
+		s := w.Size()
+		size.X = max(size.X, s.X)
+		size.Y += s.Y
+		if i > 0 {
+			size.Y += p.Spacing
+		}
+	}
+	return size.Add(image.Point{p.Padding * 2, p.Padding * 2})
+}
+
+func (p *Panel) Draw(screen *ebiten.Image, at image.Point, focus Control) {
+	if p.Background != nil {
+		size := p.Size()
+		vector.DrawFilledRect(screen, float32(at.X), float32(at.Y), float32(size.X), float32(size.Y), p.Background, false)
+	}
+
+	at = at.Add(image.Point{p.Padding, p.Padding})
+	for _, w := range p.Children {
+		w.Draw(screen, at, focus)
+		at.Y += w.Size().Y + p.Spacing
+	}
+}
+
+type UIEvent int
+
+const (
+	UINone UIEvent = iota
+	UIMoved
+	UIChanged
+	UIActivated
+	UIBack
+)
+
+// UIScreen owns the panels of one screen and moves the focus between their controls
+type UIScreen struct {
+	Panels []*Panel
+	focus  int
+}
+
+func NewUIScreen(panels ...*Panel) *UIScreen {
+	return &UIScreen{Panels: panels}
+}
+
+func collectControls(w Widget, out []Control) []Control {
+	if c, ok := w.(Control); ok {
+		out = append(out, c)
+	}
+	if c, ok := w.(container); ok {
+		for _, child := range c.children() {
+			out = collectControls(child, out)
+		}
+	}
+	return out
+}
+
+func (ui *UIScreen) controls() []Control {
+	var out []Control
+	for _, p := range ui.Panels {
+		if p.shown() {
+			out = collectControls(p, out)
+		}
+	}
+	return out
+}
+
+func (ui *UIScreen) Focused() Control {
+	controls := ui.controls()
+	if len(controls) == 0 {
+		return nil
+	}
+	return controls[min(ui.focus, len(controls)-1)]
+}
+
+type uiInput struct {
+	up, down, left, right, accept, back bool
+}
+
+// readUIInput takes the arrows, Enter and Escape or the d-pad and face buttons of any standard gamepad
+func readUIInput() uiInput {
+	in := uiInput{
+		up:     inpututil.IsKeyJustPressed(ebiten.KeyUp),
+		down:   inpututil.IsKeyJustPressed(ebiten.KeyDown),
+		left:   inpututil.IsKeyJustPressed(ebiten.KeyLeft),
+		right:  inpututil.IsKeyJustPressed(ebiten.KeyRight),
+		accept: inpututil.IsKeyJustPressed(ebiten.KeyEnter),
+		back:   inpututil.IsKeyJustPressed(ebiten.KeyEscape),
+	}
+
+	for _, id := range ebiten.AppendGamepadIDs(nil) {
+		pressed := func(b ebiten.StandardGamepadButton) bool {
+			return inpututil.IsStandardGamepadButtonJustPressed(id, b)
+		}
+		in.up = in.up || pressed(ebiten.StandardGamepadButtonLeftTop)
+		in.down = in.down || pressed(ebiten.StandardGamepadButtonLeftBottom)
+		in.left = in.left || pressed(ebiten.StandardGamepadButtonLeftLeft)
+		in.right = in.right || pressed(ebiten.StandardGamepadButtonLeftRight)
+		in.accept = in.accept || pressed(ebiten.StandardGamepadButtonRightBottom)
+		in.back = in.back || pressed(ebiten.StandardGamepadButtonRightRight)
+	}
+	return in
+}
+
+// Update handles one tick of navigation and tells the caller what happened
+func (ui *UIScreen) Update() UIEvent {
+	in := readUIInput()
+	controls := ui.controls()
+	if len(controls) == 0 {
+		if in.back {
+			return UIBack
+		}
+		return UINone
+	}
+	ui.focus = min(ui.focus, len(controls)-1)
+	focused := controls[ui.focus]
+
+	switch {
+	case in.up:
+		ui.focus = (ui.focus + len(controls) - 1) % len(controls)
+		return UIMoved
+	case in.down:
+		ui.focus = (ui.focus + 1) % len(controls)
+		return UIMoved
+	case in.left:
+		focused.Change(-1)
+		return UIChanged
+	case in.right:
+		focused.Change(1)
+		return UIChanged
+	case in.accept:
+		focused.Activate()
+		return UIActivated
+	case in.back:
+		return UIBack
+	}
+	return UINone
+}
+
+func (ui *UIScreen) Draw(screen *ebiten.Image) {
+	focus := ui.Focused()
+	for _, p := range ui.Panels {
+		if p.shown() {
+			p.Draw(screen, p.Anchor.place(screen.Bounds(), p.Size(), p.Offset), focus)
+		}
+	}
+}